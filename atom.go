@@ -0,0 +1,110 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// An atomWriter writes records as the entries of an Atom feed (RFC 4287),
+// one entry per issue.
+//
+// Each entry links to the issue on GitHub. Its title and updated time come
+// from the "title" and "updated" columns, and its summary lists the remaining
+// columns as "name: value" pairs.
+type atomWriter struct {
+	w     *bufio.Writer
+	enc   *xml.Encoder
+	names []string
+	repo  string
+	now   string // RFC 3339 timestamp for entries without an "updated" column
+}
+
+type atomEntry struct {
+	XMLName xml.Name `xml:"entry"`
+	Title   string   `xml:"title"`
+	Link    atomLink `xml:"link"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+func newAtomWriter(w io.Writer, names []string, repo string, updated time.Time) (*atomWriter, error) {
+	if !contains(names, "number") {
+		return nil, fmt.Errorf("-format=atom requires the \"number\" column")
+	}
+	a := &atomWriter{
+		w:     bufio.NewWriter(w),
+		names: names,
+		repo:  repo,
+		now:   updated.UTC().Format(time.RFC3339),
+	}
+	a.enc = xml.NewEncoder(a.w)
+
+	// The feed element is written by hand so that entries can be streamed
+	// into it; its own metadata is encoded as ordinary elements.
+	a.w.WriteString(xml.Header)
+	a.w.WriteString(`<feed xmlns="http://www.w3.org/2005/Atom">` + "\n")
+	elems := []struct {
+		name  string
+		value interface{}
+	}{
+		{"title", repo + " issues"},
+		{"id", "https://github.com/" + repo + "/issues"},
+		{"updated", a.now},
+		{"author", struct {
+			Name string `xml:"name"`
+		}{repo}},
+	}
+	for _, e := range elems {
+		if err := a.enc.EncodeElement(e.value, xml.StartElement{Name: xml.Name{Local: e.name}}); err != nil {
+			return nil, err
+		}
+		a.w.WriteString("\n")
+	}
+	return a, nil
+}
+
+func (a *atomWriter) Write(values []string) error {
+	e := atomEntry{Updated: a.now}
+	var summary []string
+	for i, v := range values {
+		switch a.names[i] {
+		case "number":
+			e.Link.Href = fmt.Sprintf("https://github.com/%s/issues/%s", a.repo, v)
+		case "title":
+			e.Title = v
+			continue
+		case "updated":
+			if t, err := time.Parse(dateFormat, v); err == nil {
+				e.Updated = t.Format(time.RFC3339)
+			}
+		}
+		if v != "" {
+			summary = append(summary, a.names[i]+": "+v)
+		}
+	}
+	e.ID = e.Link.Href
+	e.Summary = strings.Join(summary, "\n")
+	if err := a.enc.Encode(e); err != nil {
+		return err
+	}
+	_, err := a.w.WriteString("\n")
+	return err
+}
+
+func (a *atomWriter) Flush() error {
+	a.w.WriteString("</feed>\n")
+	return a.w.Flush()
+}
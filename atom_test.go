@@ -0,0 +1,65 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+
+	"golang.org/x/build/maintner/maintpb"
+)
+
+func TestAtomFeed(t *testing.T) {
+	defer withFlags(t, "format=atom", "columns=number,updated,state,title", "state=open")()
+
+	issues := testIssues(1, 2, 3)
+	issues[2].Closed = &maintpb.BoolChange{Val: true}
+	repo := newTestRepo(t, issues...)
+	out := runMode(t, exportIssues, repo, selectIssues(repo, nil, nil))
+
+	var feed struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Title   string   `xml:"title"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			Title string `xml:"title"`
+			Link  struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+			ID      string `xml:"id"`
+			Updated string `xml:"updated"`
+			Summary string `xml:"summary"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal([]byte(out), &feed); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if feed.Title != "golang/go issues" || feed.ID != "https://github.com/golang/go/issues" || feed.Updated != "2019-04-30T12:00:00Z" {
+		t.Errorf("feed title %q, id %q, updated %q; want golang/go issues, https://github.com/golang/go/issues, 2019-04-30T12:00:00Z", feed.Title, feed.ID, feed.Updated)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("feed has %d entries; want 2\n%s", len(feed.Entries), out)
+	}
+	var titles []string
+	for _, e := range feed.Entries {
+		titles = append(titles, e.Title)
+	}
+	if want := []string{"issue 1", "issue 2"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("entry titles = %q; want %q", titles, want)
+	}
+	e := feed.Entries[1]
+	const url = "https://github.com/golang/go/issues/2"
+	if e.Link.Href != url || e.ID != url {
+		t.Errorf("entry link %q, id %q; want %s", e.Link.Href, e.ID, url)
+	}
+	if e.Updated != "2019-04-30T00:00:00Z" {
+		t.Errorf("entry updated = %q; want 2019-04-30T00:00:00Z", e.Updated)
+	}
+	if want := "number: 2\nupdated: 2019-04-30\nstate: open"; e.Summary != want {
+		t.Errorf("entry summary = %q; want %q", e.Summary, want)
+	}
+}
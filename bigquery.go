@@ -0,0 +1,264 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+)
+
+// bqTypes maps the names of columns whose values are not free-form strings
+// to their BigQuery column types. Other columns are of type STRING.
+//
+// BigQuery accepts string encodings of all of these types in streamed rows,
+// so the values themselves need no conversion.
+var bqTypes = map[string]string{
+	"number":                   "INTEGER",
+	"age_days":                 "INTEGER",
+	"age_weeks":                "INTEGER",
+	"age_months":               "INTEGER",
+	"state_age_days":           "INTEGER",
+	"when_rank":                "INTEGER",
+	"title_len":                "INTEGER",
+	"cls":                      "INTEGER",
+	"assignee_open_load":       "INTEGER",
+	"untriaged_days":           "INTEGER",
+	"days_since_human_comment": "INTEGER",
+	"comments_last_30d":        "INTEGER",
+	"reopened_count":           "INTEGER",
+	"assignee_changes":         "INTEGER",
+	"cl_review_latency_days":   "INTEGER",
+	"milestone_open":           "INTEGER",
+	"label_count":              "INTEGER",
+	"open_issues":              "INTEGER",
+	"assignees":                "INTEGER",
+	"count":                    "INTEGER",
+
+	"milestone_pct":   "FLOAT",
+	"effort":          "FLOAT",
+	"discussion_heat": "FLOAT",
+
+	"labeled_recently":   "BOOLEAN",
+	"opened_recently":    "BOOLEAN",
+	"is_unreleased":      "BOOLEAN",
+	"is_help_wanted":     "BOOLEAN",
+	"blocks_release":     "BOOLEAN",
+	"assignee_stale":     "BOOLEAN",
+	"cl_stale":           "BOOLEAN",
+	"has_fix_cl":         "BOOLEAN",
+	"has_milestone":      "BOOLEAN",
+	"milestone_is_open":  "BOOLEAN",
+	"sla_breach":         "BOOLEAN",
+	"title_needs_triage": "BOOLEAN",
+	"has_test_cl":        "BOOLEAN",
+	"is_frozen":          "BOOLEAN",
+
+	"updated":              "DATE",
+	"first_assigned_at":    "DATE",
+	"assignee_last_active": "DATE",
+
+	"snapshot_at": "TIMESTAMP",
+}
+
+// A bqField describes a column of a BigQuery table.
+type bqField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode"`
+}
+
+// bqSchema returns the BigQuery schema for records with the given column names.
+func bqSchema(names []string) []bqField {
+	schema := make([]bqField, len(names))
+	for i, name := range names {
+		typ := bqTypes[name]
+		if typ == "" {
+			typ = "STRING"
+		}
+		schema[i] = bqField{Name: name, Type: typ, Mode: "NULLABLE"}
+	}
+	return schema
+}
+
+// A bqInserter streams rows into a BigQuery table.
+type bqInserter interface {
+	// ensureTable creates the table with the given schema if it does not
+	// already exist.
+	ensureTable(ctx context.Context, schema []bqField) error
+
+	// insert appends rows to the table. Each row maps column names to values;
+	// columns missing from a row are NULL.
+	insert(ctx context.Context, rows []map[string]string) error
+}
+
+// bqBatchSize is the number of rows sent in each insertAll request.
+const bqBatchSize = 500
+
+// A bqWriter is a recordWriter that streams records to BigQuery.
+type bqWriter struct {
+	ctx   context.Context
+	ins   bqInserter
+	names []string
+	rows  []map[string]string
+}
+
+func newBQWriter(ctx context.Context, ins bqInserter, names []string) (*bqWriter, error) {
+	if err := ins.ensureTable(ctx, bqSchema(names)); err != nil {
+		return nil, err
+	}
+	return &bqWriter{ctx: ctx, ins: ins, names: names}, nil
+}
+
+func (b *bqWriter) Write(values []string) error {
+	row := make(map[string]string, len(values))
+	for i, v := range values {
+		// Empty values are NULL, since they may not parse as the column's type.
+		if v != "" {
+			row[b.names[i]] = v
+		}
+	}
+	b.rows = append(b.rows, row)
+	if len(b.rows) < bqBatchSize {
+		return nil
+	}
+	return b.Flush()
+}
+
+func (b *bqWriter) Flush() error {
+	if len(b.rows) == 0 {
+		return nil
+	}
+	err := b.ins.insert(b.ctx, b.rows)
+	b.rows = b.rows[:0]
+	return err
+}
+
+// A restInserter is a bqInserter that uses the BigQuery REST API.
+type restInserter struct {
+	client                  *http.Client
+	project, dataset, table string
+}
+
+// newRESTInserter returns a restInserter for the table named by a string of
+// the form "project.dataset.table", authenticated with the application
+// default credentials.
+func newRESTInserter(ctx context.Context, table string) (*restInserter, error) {
+	parts := strings.Split(table, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid BigQuery table %q (want project.dataset.table)", table)
+	}
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/bigquery")
+	if err != nil {
+		return nil, err
+	}
+	return &restInserter{client: client, project: parts[0], dataset: parts[1], table: parts[2]}, nil
+}
+
+func (r *restInserter) datasetURL() string {
+	return fmt.Sprintf("https://bigquery.googleapis.com/bigquery/v2/projects/%s/datasets/%s", r.project, r.dataset)
+}
+
+func (r *restInserter) ensureTable(ctx context.Context, schema []bqField) error {
+	tableURL := r.datasetURL() + "/tables/" + r.table
+	resp, err := r.do(ctx, "GET", tableURL, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+	default:
+		return fmt.Errorf("GET %s: %s", tableURL, resp.Status)
+	}
+
+	req := map[string]interface{}{
+		"tableReference": map[string]string{
+			"projectId": r.project,
+			"datasetId": r.dataset,
+			"tableId":   r.table,
+		},
+		"schema": map[string]interface{}{"fields": schema},
+	}
+	return r.post(ctx, r.datasetURL()+"/tables", req, nil)
+}
+
+func (r *restInserter) insert(ctx context.Context, rows []map[string]string) error {
+	type insertRow struct {
+		JSON map[string]string `json:"json"`
+	}
+	req := struct {
+		Rows []insertRow `json:"rows"`
+	}{}
+	for _, row := range rows {
+		req.Rows = append(req.Rows, insertRow{row})
+	}
+
+	var resp struct {
+		InsertErrors []struct {
+			Index  int `json:"index"`
+			Errors []struct {
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+	if err := r.post(ctx, r.datasetURL()+"/tables/"+r.table+"/insertAll", req, &resp); err != nil {
+		return err
+	}
+	if len(resp.InsertErrors) > 0 {
+		e := resp.InsertErrors[0]
+		msg := "unknown error"
+		if len(e.Errors) > 0 {
+			msg = e.Errors[0].Reason + ": " + e.Errors[0].Message
+		}
+		return fmt.Errorf("inserting into %s.%s.%s: %d rows rejected; first (row %d): %s", r.project, r.dataset, r.table, len(resp.InsertErrors), e.Index, msg)
+	}
+	return nil
+}
+
+// post sends req as JSON to url and, if resp is non-nil, decodes the JSON
+// response into it.
+func (r *restInserter) post(ctx context.Context, url string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	hresp, err := r.do(ctx, "POST", url, body)
+	if err != nil {
+		return err
+	}
+	defer hresp.Body.Close()
+	data, err := ioutil.ReadAll(hresp.Body)
+	if err != nil {
+		return err
+	}
+	if hresp.StatusCode != http.StatusOK {
+		return fmt.Errorf("POST %s: %s\n%s", url, hresp.Status, data)
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(data, resp)
+}
+
+func (r *restInserter) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return r.client.Do(req.WithContext(ctx))
+}
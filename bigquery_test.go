@@ -0,0 +1,87 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// A fakeInserter is a bqInserter that records the schema and rows it is sent.
+type fakeInserter struct {
+	schema  []bqField
+	batches [][]map[string]string
+}
+
+func (f *fakeInserter) ensureTable(ctx context.Context, schema []bqField) error {
+	f.schema = schema
+	return nil
+}
+
+func (f *fakeInserter) insert(ctx context.Context, rows []map[string]string) error {
+	// The writer reuses its buffer after each insert, so copy the rows.
+	f.batches = append(f.batches, append([]map[string]string(nil), rows...))
+	return nil
+}
+
+func TestBQSchema(t *testing.T) {
+	names := []string{"number", "title", "effort", "has_milestone", "is_frozen", "updated", "snapshot_at"}
+	want := []bqField{
+		{"number", "INTEGER", "NULLABLE"},
+		{"title", "STRING", "NULLABLE"},
+		{"effort", "FLOAT", "NULLABLE"},
+		{"has_milestone", "BOOLEAN", "NULLABLE"},
+		{"is_frozen", "BOOLEAN", "NULLABLE"},
+		{"updated", "DATE", "NULLABLE"},
+		{"snapshot_at", "TIMESTAMP", "NULLABLE"},
+	}
+	if got := bqSchema(names); !reflect.DeepEqual(got, want) {
+		t.Errorf("bqSchema(%q) =\n%v\nwant:\n%v", names, got, want)
+	}
+}
+
+func TestBQWriter(t *testing.T) {
+	ins := new(fakeInserter)
+	names := []string{"number", "title", "first_assigned_at"}
+	w, err := newBQWriter(context.Background(), ins, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := bqSchema(names); !reflect.DeepEqual(ins.schema, want) {
+		t.Errorf("table created with schema %v; want %v", ins.schema, want)
+	}
+
+	const n = bqBatchSize + 2
+	for i := 1; i <= n; i++ {
+		assigned := ""
+		if i%2 == 0 {
+			assigned = "2019-04-01"
+		}
+		if err := w.Write([]string{fmt.Sprint(i), fmt.Sprintf("issue %d", i), assigned}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ins.batches) != 2 || len(ins.batches[0]) != bqBatchSize || len(ins.batches[1]) != 2 {
+		var sizes []int
+		for _, b := range ins.batches {
+			sizes = append(sizes, len(b))
+		}
+		t.Fatalf("inserted batches of %v rows; want [%d 2]", sizes, bqBatchSize)
+	}
+	// Empty values are omitted, so that BigQuery stores them as NULL.
+	want := []map[string]string{
+		{"number": fmt.Sprint(n - 1), "title": fmt.Sprintf("issue %d", n-1)},
+		{"number": fmt.Sprint(n), "title": fmt.Sprintf("issue %d", n), "first_assigned_at": "2019-04-01"},
+	}
+	if got := ins.batches[1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("last batch = %v; want %v", got, want)
+	}
+}
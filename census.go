@@ -0,0 +1,114 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"golang.org/x/build/maintner"
+)
+
+// A census holds counts gathered in a pre-pass over every issue in the repo,
+// for columns that describe an issue relative to the others.
+type census struct {
+	milestones map[int64]*milestoneCount // by milestone ID
+	assigned   map[string]int            // open issues assigned to each login
+
+	// cycle is the title of the milestone for the release currently in
+	// development: either -cycle, or the earliest open Go1.N milestone.
+	cycle string
+
+	// snapshot approximates the time at which the corpus was captured,
+	// as the latest update to any issue or pull request. (Maintner does not
+	// record the time of its last sync.)
+	snapshot time.Time
+}
+
+// A milestoneCount counts the issues in a milestone.
+type milestoneCount struct {
+	open, closed int
+}
+
+func takeCensus(repo *maintner.GitHubRepo) *census {
+	c := &census{
+		milestones: map[int64]*milestoneCount{},
+		assigned:   map[string]int{},
+	}
+	c.cycle = *cycle
+	if c.cycle == "" {
+		c.cycle = activeCycle(repo)
+	}
+	repo.ForeachIssue(func(gi *maintner.GitHubIssue) error {
+		if gi.NotExist {
+			return nil
+		}
+		if gi.Updated.After(c.snapshot) {
+			c.snapshot = gi.Updated
+		}
+		if gi.PullRequest {
+			return nil
+		}
+		if !gi.Closed {
+			for _, a := range gi.Assignees {
+				if a.Login != "" {
+					c.assigned[a.Login]++
+				}
+			}
+		}
+		if m := milestone(gi); m != nil {
+			mc := c.milestones[m.ID]
+			if mc == nil {
+				mc = new(milestoneCount)
+				c.milestones[m.ID] = mc
+			}
+			if gi.Closed {
+				mc.closed++
+			} else {
+				mc.open++
+			}
+		}
+		return nil
+	})
+	return c
+}
+
+// milestone returns gi's milestone, or nil if it has none.
+func milestone(gi *maintner.GitHubIssue) *maintner.GitHubMilestone {
+	if gi.Milestone == nil || gi.Milestone.IsNone() {
+		return nil
+	}
+	return gi.Milestone
+}
+
+var releaseMilestoneRE = regexp.MustCompile(`^Go1\.([0-9]+)$`)
+
+// activeCycle returns the title of the earliest open release milestone
+// (of the form Go1.N) in repo, or "" if there is none.
+func activeCycle(repo *maintner.GitHubRepo) string {
+	var (
+		title string
+		minor = -1
+	)
+	repo.ForeachMilestone(func(m *maintner.GitHubMilestone) error {
+		if m.Closed {
+			return nil
+		}
+		match := releaseMilestoneRE.FindStringSubmatch(m.Title)
+		if match == nil {
+			return nil
+		}
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil
+		}
+		if minor < 0 || n < minor {
+			title, minor = m.Title, n
+		}
+		return nil
+	})
+	return title
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"golang.org/x/build/maintner"
+)
+
+// labelNames maps each label ID used by goissues to the name it had on
+// GitHub when the ID was extracted.
+var labelNames = map[int64]string{
+	go2ID:                "Go2",
+	documentationID:      "Documentation",
+	earlyInCycleID:       "early-in-cycle",
+	featureRequestID:     "FeatureRequest",
+	helpWantedID:         "help wanted",
+	needsDecisionID:      "NeedsDecision",
+	needsFixID:           "NeedsFix",
+	needsInvestigationID: "NeedsInvestigation",
+	performanceID:        "Performance",
+	proposalID:           "Proposal",
+	proposalHoldID:       "Proposal-Hold",
+	releaseBlockerID:     "release-blocker",
+	soonID:               "Soon",
+	testingID:            "Testing",
+	toolSpeedID:          "ToolSpeed",
+	waitingForInfoID:     "WaitingForInfo",
+	frozenDueToAgeID:     "FrozenDueToAge",
+}
+
+// milestoneNames maps each milestone number used by goissues to the title it
+// had on GitHub when the number was extracted.
+var milestoneNames = map[int32]string{
+	unplannedMilestone:  "Unplanned",
+	unreleasedMilestone: "Unreleased",
+	proposalMilestone:   "Proposal",
+	go2Milestone:        "Go2",
+	gccgoMilestone:      "Gccgo",
+	gollvmMilestone:     "Gollvm",
+}
+
+// A checkMode is the value of the -check-ids flag.
+//
+// The flag may be given without a value (meaning "warn") or as
+// -check-ids=strict.
+type checkMode string
+
+const (
+	checkOff    checkMode = ""
+	checkWarn   checkMode = "warn"
+	checkStrict checkMode = "strict"
+)
+
+func (m *checkMode) String() string { return string(*m) }
+
+func (m *checkMode) Set(s string) error {
+	switch s {
+	case "false":
+		*m = checkOff
+	case "true", "warn":
+		*m = checkWarn
+	case "strict":
+		*m = checkStrict
+	default:
+		return fmt.Errorf("unrecognized mode %q (want warn or strict)", s)
+	}
+	return nil
+}
+
+func (m *checkMode) IsBoolFlag() bool { return true }
+
+// checkIDs reports the label IDs and milestone numbers used by goissues that
+// do not resolve in repo, either in its metadata or on any of its issues.
+//
+// The IDs are hard-coded, so if a label or milestone is deleted and recreated
+// on GitHub, issues carrying it would otherwise be silently misclassified.
+func checkIDs(repo *maintner.GitHubRepo) (missing []string) {
+	labels := map[int64]bool{}
+	milestones := map[int32]bool{}
+
+	repo.ForeachLabel(func(l *maintner.GitHubLabel) error {
+		labels[l.ID] = true
+		return nil
+	})
+	repo.ForeachMilestone(func(m *maintner.GitHubMilestone) error {
+		milestones[m.Number] = true
+		return nil
+	})
+	repo.ForeachIssue(func(gi *maintner.GitHubIssue) error {
+		for id := range gi.Labels {
+			labels[id] = true
+		}
+		if gi.Milestone != nil && !gi.Milestone.IsNone() {
+			milestones[gi.Milestone.Number] = true
+		}
+		return nil
+	})
+
+	for id, name := range labelNames {
+		if !labels[id] {
+			missing = append(missing, fmt.Sprintf("label %q (ID %d)", name, id))
+		}
+	}
+	for n, title := range milestoneNames {
+		if !milestones[n] {
+			missing = append(missing, fmt.Sprintf("milestone %q (number %d)", title, n))
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// runCheckIDs runs checkIDs on repo and logs the results according to mode.
+func runCheckIDs(repo *maintner.GitHubRepo, mode checkMode) {
+	if mode == checkOff {
+		return
+	}
+	missing := checkIDs(repo)
+	for _, m := range missing {
+		log.Printf("warning: %s not found in %s", m, repo.ID())
+	}
+	if len(missing) > 0 && mode == checkStrict {
+		fatalf(exitFailure, "%d label or milestone IDs not found; update the constants in goissues", len(missing))
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/build/maintner/maintpb"
+)
+
+func TestCheckIDs(t *testing.T) {
+	// The repo metadata lists every label except Soon and Documentation,
+	// and Documentation appears on an issue.
+	meta := &maintpb.GithubMutation{Owner: "golang", Repo: "go"}
+	for id, name := range labelNames {
+		if id != soonID && id != documentationID {
+			meta.Labels = append(meta.Labels, &maintpb.GithubLabel{Id: id, Name: name})
+		}
+	}
+	for n, title := range milestoneNames {
+		meta.Milestones = append(meta.Milestones, &maintpb.GithubMilestone{Id: int64(1000 + n), Number: int64(n), Title: title})
+	}
+	issue := &maintpb.GithubIssueMutation{
+		Owner:    "golang",
+		Repo:     "go",
+		Number:   1,
+		Created:  ts(testNow),
+		Title:    "doc: fix typo",
+		AddLabel: []*maintpb.GithubLabel{{Id: documentationID, Name: "Documentation"}},
+	}
+	repo := newTestCorpus(t, &maintpb.Mutation{Github: meta}, &maintpb.Mutation{GithubIssue: issue}).GitHub().Repo("golang", "go")
+
+	got := checkIDs(repo)
+	want := []string{`label "Soon" (ID 936464699)`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checkIDs(…) = %q; want %q", got, want)
+	}
+}
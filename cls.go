@@ -0,0 +1,262 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/build/maintner"
+)
+
+// issueCLs records the Gerrit CLs that refer to a GitHub issue.
+type issueCLs struct {
+	// live and vetoed are the latest open CLs for each change, with and
+	// without a -2 Code-Review vote respectively.
+	live   []*maintner.GerritCL
+	vetoed []*maintner.GerritCL
+
+	// all is every CL that refers to the issue, in any status, including
+	// those superseded by later CLs for the same change.
+	all []*maintner.GerritCL
+
+	// hasFix and hasTest report whether any CL in all that was not abandoned
+	// appears to fix the issue, or only to add a test or reproducer for it.
+	// See isTestCL.
+	hasFix, hasTest bool
+
+	// liveUpdated is the time of the latest update to any CL in live.
+	liveUpdated time.Time
+}
+
+// count returns the number of open CLs in ic.
+func (ic *issueCLs) count() int {
+	if ic == nil {
+		return 0
+	}
+	return len(ic.live) + len(ic.vetoed)
+}
+
+// stale reports whether every CL in ic.live has gone at least window
+// without an update. ok is false if there are no live CLs.
+func (ic *issueCLs) stale(window time.Duration) (stale, ok bool) {
+	if ic == nil || len(ic.live) == 0 {
+		return false, false
+	}
+	return now.Sub(ic.liveUpdated) >= window, true
+}
+
+// numbers returns the comma-separated numbers of the CLs in ic.all whose
+// status is one of the given statuses.
+func (ic *issueCLs) numbers(statuses ...string) string {
+	if ic == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, cl := range ic.all {
+		if !contains(statuses, cl.Status) {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(strconv.FormatInt(int64(cl.Number), 10))
+	}
+	return b.String()
+}
+
+var testSubjectRE = regexp.MustCompile(`(?i)\b(tests?|repro|reproducer|reproduces?)\b`)
+
+// isTestCL reports whether a CL with the given subject appears to add only a
+// test or reproducer, rather than a fix.
+//
+// This is a heuristic: it checks whether the subject is in the "test"
+// directory, or whether its description (after the package prefix) mentions
+// tests or reproducers. A fix that also adds a test may be misclassified if
+// its subject mentions only the test.
+func isTestCL(subject string) bool {
+	desc := subject
+	if i := strings.Index(subject, ": "); i >= 0 {
+		if strings.TrimSpace(subject[:i]) == "test" {
+			return true
+		}
+		desc = subject[i+2:]
+	}
+	return testSubjectRE.MatchString(desc)
+}
+
+// supersedes reports whether cl should represent its change instead of prev,
+// another CL with the same Change-Id on the same branch.
+//
+// An open CL is preferred over a closed one: a change that is still under
+// review should not look abandoned because a later re-upload was abandoned
+// or merged. Otherwise, the more recently created CL is preferred.
+func supersedes(cl, prev *maintner.GerritCL) bool {
+	if open, prevOpen := isOpenCL(cl), isOpenCL(prev); open != prevOpen {
+		return open
+	}
+	if !cl.Created.Equal(prev.Created) {
+		return cl.Created.After(prev.Created)
+	}
+	return cl.Number > prev.Number
+}
+
+// isOpenCL reports whether cl is still open for review.
+func isOpenCL(cl *maintner.GerritCL) bool {
+	return cl.Status == "new" || cl.Status == "draft"
+}
+
+// clUpdated returns the time of the latest update to cl: the time of its
+// latest meta commit, which records new patch sets, votes, and comments.
+func clUpdated(cl *maintner.GerritCL) time.Time {
+	if len(cl.Metas) == 0 {
+		return cl.Created
+	}
+	return cl.Metas[len(cl.Metas)-1].Commit.CommitTime
+}
+
+// lastReviewVote returns the time of the latest meta commit on cl that sets a
+// Code-Review vote, or the zero time if there is none.
+func lastReviewVote(cl *maintner.GerritCL) time.Time {
+	for j := len(cl.Metas) - 1; j >= 0; j-- {
+		m := cl.Metas[j]
+		if strings.Contains("\n"+m.Footer(), "\nLabel: Code-Review") {
+			return m.Commit.CommitTime
+		}
+	}
+	return time.Time{}
+}
+
+// newestLive returns the most recently created CL in ic.live, or nil if
+// there are none.
+func (ic *issueCLs) newestLive() *maintner.GerritCL {
+	if ic == nil {
+		return nil
+	}
+	var newest *maintner.GerritCL
+	for _, cl := range ic.live {
+		if newest == nil || cl.Created.After(newest.Created) {
+			newest = cl
+		}
+	}
+	return newest
+}
+
+// scanCLs returns the CLs in project that refer to issues in repo,
+// indexed by issue number.
+//
+// Gerrit may have more than one CL for the same change: for example, if the
+// change was abandoned and then re-uploaded. scanCLs considers only the latest
+// CL for each Change-Id on each branch, preferring open CLs over closed ones,
+// so that each change is counted once.
+// CLs without a Change-Id are each considered separately.
+//
+// If -cl-branch is set, CLs targeting other branches are ignored entirely.
+func scanCLs(project *maintner.GerritProject, repo *maintner.GitHubRepo) (map[int32]*issueCLs, error) {
+	type changeKey struct{ branch, id string }
+	latest := map[changeKey]*maintner.GerritCL{}
+	var all, unkeyed []*maintner.GerritCL
+
+	err := project.ForeachCLUnsorted(func(cl *maintner.GerritCL) error {
+		if cl.Private {
+			return nil
+		}
+		if *clBranch != "" && cl.Branch() != *clBranch {
+			return nil
+		}
+		hasRef := false
+		for _, ref := range cl.GitHubIssueRefs {
+			if ref.Repo == repo {
+				hasRef = true
+				break
+			}
+		}
+		if !hasRef {
+			return nil
+		}
+		all = append(all, cl)
+
+		id := cl.ChangeID()
+		if id == "" {
+			unkeyed = append(unkeyed, cl)
+			return nil
+		}
+		k := changeKey{cl.Branch(), id}
+		if prev := latest[k]; prev == nil || supersedes(cl, prev) {
+			latest[k] = cl
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cls := unkeyed
+	for _, cl := range latest {
+		cls = append(cls, cl)
+	}
+	sort.Slice(cls, func(i, j int) bool { return cls[i].Number < cls[j].Number })
+
+	refs := map[int32]*issueCLs{}
+	get := func(number int32) *issueCLs {
+		ic := refs[number]
+		if ic == nil {
+			ic = new(issueCLs)
+			refs[number] = ic
+		}
+		return ic
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Number < all[j].Number })
+	for _, cl := range all {
+		for _, ref := range cl.GitHubIssueRefs {
+			if ref.Repo == repo {
+				ic := get(ref.Number)
+				ic.all = append(ic.all, cl)
+				if cl.Status != "abandoned" {
+					if isTestCL(cl.Subject()) {
+						ic.hasTest = true
+					} else {
+						ic.hasFix = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, cl := range cls {
+		if cl.Status != "new" {
+			continue
+		}
+		vetoed := false
+		if len(cl.Metas) >= 1 {
+			meta := cl.Metas[len(cl.Metas)-1]
+			for _, vote := range meta.LabelVotes()["Code-Review"] {
+				if vote == -2 {
+					vetoed = true
+					break
+				}
+			}
+		}
+		for _, ref := range cl.GitHubIssueRefs {
+			if ref.Repo != repo {
+				continue
+			}
+			ic := get(ref.Number)
+			if vetoed {
+				ic.vetoed = append(ic.vetoed, cl)
+			} else {
+				ic.live = append(ic.live, cl)
+				if t := clUpdated(cl); t.After(ic.liveUpdated) {
+					ic.liveUpdated = t
+				}
+			}
+		}
+	}
+	return refs, nil
+}
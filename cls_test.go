@@ -0,0 +1,403 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/build/maintner"
+	"golang.org/x/build/maintner/maintpb"
+)
+
+// A testCL describes a Gerrit CL in a fixture corpus.
+type testCL struct {
+	number   int32
+	changeID string    // if non-empty, a seed for the CL's Change-Id
+	branch   string    // if empty, "master"
+	status   string    // if empty, "new"
+	subject  string    // if empty, "all: fix the bug"
+	fixes    []int32   // golang/go issues that the CL refers to
+	created  time.Time // if zero, one week before testNow
+	votes    []testVote
+}
+
+// A testVote is a label vote on a testCL, such as "Code-Review=-2".
+type testVote struct {
+	at    time.Time
+	label string
+}
+
+// changeID returns a valid Change-Id derived from seed.
+func changeID(seed string) string {
+	return fmt.Sprintf("I%x", sha1.Sum([]byte(seed)))
+}
+
+// gitCommit returns a git commit with the given parent (if any), commit time,
+// and message, along with its hash.
+func gitCommit(parent string, at time.Time, msg string) (*maintpb.GitCommit, string) {
+	var b strings.Builder
+	b.WriteString("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n")
+	if parent != "" {
+		fmt.Fprintf(&b, "parent %s\n", parent)
+	}
+	person := fmt.Sprintf("Gerrit User 1 <1@62eb7196-b449-3ce5-99f1-c037f21e1705> %d +0000", at.Unix())
+	fmt.Fprintf(&b, "author %s\ncommitter %s\n\n%s", person, person, msg)
+	raw := b.String()
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(raw)))
+	return &maintpb.GitCommit{Sha1: hash, Raw: []byte(raw)}, hash
+}
+
+// mutation returns a mutation adding cl to the go.googlesource.com/go project.
+func (cl testCL) mutation() *maintpb.Mutation {
+	var (
+		branch  = cl.branch
+		status  = cl.status
+		subject = cl.subject
+		created = cl.created
+	)
+	if branch == "" {
+		branch = "master"
+	}
+	if status == "" {
+		status = "new"
+	}
+	if subject == "" {
+		subject = "all: fix the bug"
+	}
+	if created.IsZero() {
+		created = testNow.AddDate(0, 0, -7)
+	}
+
+	msg := subject + "\n\n"
+	for _, n := range cl.fixes {
+		msg += fmt.Sprintf("Fixes #%d.\n", n)
+	}
+	if cl.changeID != "" {
+		msg += "\nChange-Id: " + changeID(cl.changeID) + "\n"
+	}
+	patchSet, patchSetHash := gitCommit("", created, msg)
+	commits := []*maintpb.GitCommit{patchSet}
+
+	meta, metaHash := gitCommit("", created, fmt.Sprintf("Create change\n\nUploaded patch set 1.\n\nPatch-set: 1\nBranch: refs/heads/%s\nStatus: new\nCommit: %s\n", branch, patchSetHash))
+	commits = append(commits, meta)
+	last := created
+	for _, v := range cl.votes {
+		meta, metaHash = gitCommit(metaHash, v.at, fmt.Sprintf("Update patch set 1\n\nPatch Set 1: %s\n\nPatch-set: 1\nLabel: %s\n", v.label, v.label))
+		commits = append(commits, meta)
+		last = v.at
+	}
+	if status != "new" {
+		meta, metaHash = gitCommit(metaHash, last.Add(time.Hour), fmt.Sprintf("Update patch set 1\n\nPatch-set: 1\nStatus: %s\n", status))
+		commits = append(commits, meta)
+	}
+
+	prefix := fmt.Sprintf("refs/changes/%02d/%d/", cl.number%100, cl.number)
+	return &maintpb.Mutation{Gerrit: &maintpb.GerritMutation{
+		Project: "go.googlesource.com/go",
+		Commits: commits,
+		Refs: []*maintpb.GitRef{
+			{Ref: prefix + "1", Sha1: patchSetHash},
+			{Ref: prefix + "meta", Sha1: metaHash},
+		},
+	}}
+}
+
+// newTestCLs returns the golang/go repo of a corpus holding the given issues
+// and CLs, along with the CLs that scanCLs finds for each issue.
+func newTestCLs(t *testing.T, issues []*maintpb.GithubIssueMutation, cls ...testCL) (*maintner.GitHubRepo, map[int32]*issueCLs) {
+	t.Helper()
+	muts := []*maintpb.Mutation{{Github: &maintpb.GithubMutation{Owner: "golang", Repo: "go"}}}
+	for _, m := range issues {
+		m.Owner, m.Repo = "golang", "go"
+		muts = append(muts, &maintpb.Mutation{GithubIssue: m})
+	}
+	for _, cl := range cls {
+		muts = append(muts, cl.mutation())
+	}
+	corpus := newTestCorpus(t, muts...)
+	repo := corpus.GitHub().Repo("golang", "go")
+	project := corpus.Gerrit().Project("go.googlesource.com", "go")
+	if project == nil {
+		t.Fatal("go.googlesource.com/go not found in fixture corpus")
+	}
+	refs, err := scanCLs(project, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo, refs
+}
+
+// testIssues returns mutations for open issues with the given numbers.
+func testIssues(numbers ...int32) []*maintpb.GithubIssueMutation {
+	var issues []*maintpb.GithubIssueMutation
+	for _, n := range numbers {
+		issues = append(issues, &maintpb.GithubIssueMutation{
+			Number:  n,
+			Created: ts(testNow.AddDate(0, -1, 0)),
+			Updated: ts(testNow.AddDate(0, 0, -1)),
+			Title:   fmt.Sprintf("issue %d", n),
+		})
+	}
+	return issues
+}
+
+func TestBlockedState(t *testing.T) {
+	defer withFlags(t)()
+
+	repo, refs := newTestCLs(t, testIssues(1, 2, 3),
+		testCL{number: 101, fixes: []int32{1}, votes: []testVote{{testNow.AddDate(0, 0, -3), "Code-Review=-2"}}},
+		testCL{number: 102, fixes: []int32{2}, votes: []testVote{{testNow.AddDate(0, 0, -3), "Code-Review=+1"}}},
+	)
+
+	for n, want := range map[int32]string{1: "blocked", 2: "pending", 3: "open"} {
+		i := newIssue(repo.Issue(n), refs[n])
+		if i.state != want {
+			t.Errorf("#%d: state = %q; want %q", n, i.state, want)
+		}
+	}
+	if got := refs[1].count(); got != 1 {
+		t.Errorf("#1: cls.count() = %d; want 1", got)
+	}
+}
+
+// clNumbers returns the numbers of cls.
+func clNumbers(cls []*maintner.GerritCL) []int32 {
+	var numbers []int32
+	for _, cl := range cls {
+		numbers = append(numbers, cl.Number)
+	}
+	return numbers
+}
+
+func TestDedupeChangeIDs(t *testing.T) {
+	defer withFlags(t)()
+
+	day := func(n int) time.Time { return testNow.AddDate(0, 0, n) }
+	_, refs := newTestCLs(t, testIssues(1, 2, 3, 4, 5),
+		// Two open CLs for the same change.
+		testCL{number: 501, changeID: "e", fixes: []int32{5}, created: day(-10)},
+		testCL{number: 502, changeID: "e", fixes: []int32{5}, created: day(-5)},
+
+		// An abandoned CL re-uploaded as a new one.
+		testCL{number: 101, changeID: "a", fixes: []int32{1}, status: "abandoned", created: day(-10)},
+		testCL{number: 102, changeID: "a", fixes: []int32{1}, created: day(-5)},
+
+		// An open CL whose later re-upload was abandoned.
+		testCL{number: 201, changeID: "b", fixes: []int32{2}, created: day(-10)},
+		testCL{number: 202, changeID: "b", fixes: []int32{2}, status: "abandoned", created: day(-5)},
+
+		// The same change on two branches.
+		testCL{number: 301, changeID: "c", fixes: []int32{3}, created: day(-10)},
+		testCL{number: 302, changeID: "c", fixes: []int32{3}, branch: "release-branch.go1.12", created: day(-5)},
+
+		// CLs without a Change-Id.
+		testCL{number: 401, fixes: []int32{4}, created: day(-10)},
+		testCL{number: 402, fixes: []int32{4}, created: day(-5)},
+	)
+
+	for n, want := range map[int32][]int32{1: {102}, 2: {201}, 3: {301, 302}, 4: {401, 402}, 5: {502}} {
+		if got := clNumbers(refs[n].live); !reflect.DeepEqual(got, want) {
+			t.Errorf("#%d: live CLs = %v; want %v", n, got, want)
+		}
+		if got := refs[n].count(); got != len(want) {
+			t.Errorf("#%d: count() = %d; want %d", n, got, len(want))
+		}
+	}
+	if got, want := clNumbers(refs[1].all), []int32{101, 102}; !reflect.DeepEqual(got, want) {
+		t.Errorf("#1: all CLs = %v; want %v", got, want)
+	}
+}
+
+func TestCLsByStatus(t *testing.T) {
+	defer withFlags(t)()
+
+	repo, refs := newTestCLs(t, testIssues(1, 2),
+		testCL{number: 101, fixes: []int32{1}, status: "merged"},
+		testCL{number: 102, fixes: []int32{1}, status: "abandoned"},
+		testCL{number: 103, fixes: []int32{1}},
+		testCL{number: 104, fixes: []int32{1}, status: "abandoned"},
+		testCL{number: 105, fixes: []int32{1}, votes: []testVote{{testNow.AddDate(0, 0, -1), "Code-Review=-2"}}},
+	)
+
+	for _, tt := range []struct {
+		number int32
+		column string
+		want   string
+	}{
+		{1, "open_cls", "103,105"},
+		{1, "merged_cls", "101"},
+		{1, "abandoned_cls", "102,104"},
+		{2, "open_cls", ""},
+		{2, "merged_cls", ""},
+		{2, "abandoned_cls", ""},
+	} {
+		i := newIssue(repo.Issue(tt.number), refs[tt.number])
+		if got := columnValue(t, repo, nil, tt.column, i); got != tt.want {
+			t.Errorf("#%d: %s = %q; want %q", tt.number, tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestIsTestCL(t *testing.T) {
+	for _, tt := range []struct {
+		subject string
+		want    bool
+	}{
+		{"test: add a reproducer for issue 1", true},
+		{"net/http: add test for Transport leak", true},
+		{"cmd/go: add repro for module cache corruption", true},
+		{"runtime: add tests", true},
+		{"testing: fix flaky Benchmark output", false},
+		{"net/http: fix Transport leak", false},
+		{"cmd/go: fix latest lookup", false},
+		{"all: attest to the binaries", false},
+	} {
+		if got := isTestCL(tt.subject); got != tt.want {
+			t.Errorf("isTestCL(%q) = %v; want %v", tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestHasFixAndTestCL(t *testing.T) {
+	defer withFlags(t)()
+
+	repo, refs := newTestCLs(t, testIssues(1, 2, 3, 4, 5),
+		testCL{number: 101, fixes: []int32{1}, subject: "net/http: fix Transport leak"},
+		testCL{number: 201, fixes: []int32{2}, subject: "net/http: add test for Transport leak"},
+		testCL{number: 301, fixes: []int32{3}, subject: "test: add a reproducer"},
+		testCL{number: 302, fixes: []int32{3}, subject: "cmd/compile: fix the crash", status: "merged"},
+		// Abandoned CLs are ignored.
+		testCL{number: 401, fixes: []int32{4}, subject: "cmd/compile: fix the crash", status: "abandoned"},
+	)
+
+	for _, tt := range []struct {
+		number          int32
+		hasFix, hasTest string
+	}{
+		{1, "true", "false"},
+		{2, "false", "true"},
+		{3, "true", "true"},
+		{4, "false", "false"},
+		{5, "false", "false"},
+	} {
+		i := newIssue(repo.Issue(tt.number), refs[tt.number])
+		if got := columnValue(t, repo, nil, "has_fix_cl", i); got != tt.hasFix {
+			t.Errorf("#%d: has_fix_cl = %q; want %q", tt.number, got, tt.hasFix)
+		}
+		if got := columnValue(t, repo, nil, "has_test_cl", i); got != tt.hasTest {
+			t.Errorf("#%d: has_test_cl = %q; want %q", tt.number, got, tt.hasTest)
+		}
+	}
+}
+
+func TestCLBranch(t *testing.T) {
+	cls := []testCL{
+		{number: 101, fixes: []int32{1}},
+		{number: 102, fixes: []int32{1}, branch: "release-branch.go1.12"},
+		{number: 201, fixes: []int32{2}, branch: "release-branch.go1.12"},
+		{number: 301, fixes: []int32{3}, status: "merged"},
+	}
+	for _, tt := range []struct {
+		flags  []string
+		states map[int32]string
+		open   map[int32]string
+	}{
+		{
+			nil,
+			map[int32]string{1: "pending", 2: "pending", 3: "open"},
+			map[int32]string{1: "101,102", 2: "201", 3: ""},
+		},
+		{
+			[]string{"cl-branch=master"},
+			map[int32]string{1: "pending", 2: "open", 3: "open"},
+			map[int32]string{1: "101", 2: "", 3: ""},
+		},
+		{
+			[]string{"cl-branch=release-branch.go1.12"},
+			map[int32]string{1: "pending", 2: "pending", 3: "open"},
+			map[int32]string{1: "102", 2: "201", 3: ""},
+		},
+	} {
+		restore := withFlags(t, tt.flags...)
+		repo, refs := newTestCLs(t, testIssues(1, 2, 3), cls...)
+		for n, want := range tt.states {
+			i := newIssue(repo.Issue(n), refs[n])
+			if i.state != want {
+				t.Errorf("with flags %q, #%d: state = %q; want %q", tt.flags, n, i.state, want)
+			}
+			if got := columnValue(t, repo, nil, "open_cls", i); got != tt.open[n] {
+				t.Errorf("with flags %q, #%d: open_cls = %q; want %q", tt.flags, n, got, tt.open[n])
+			}
+		}
+		restore()
+	}
+}
+
+func TestCLStale(t *testing.T) {
+	day := func(n int) time.Time { return testNow.AddDate(0, 0, n) }
+	cls := []testCL{
+		{number: 101, fixes: []int32{1}, created: day(-20)},
+		{number: 201, fixes: []int32{2}, created: day(-20), votes: []testVote{{day(-1), "Code-Review=+1"}}},
+		{number: 301, fixes: []int32{3}, created: day(-20)},
+		{number: 302, fixes: []int32{3}, created: day(-2)},
+	}
+
+	defer withFlags(t, "cl-stale=168h")()
+	repo, refs := newTestCLs(t, testIssues(1, 2, 3, 4), cls...)
+	var issues []*issue
+	for _, n := range []int32{1, 2, 3, 4} {
+		issues = append(issues, newIssue(repo.Issue(n), refs[n]))
+	}
+
+	for j, want := range []string{"true", "false", "false", ""} {
+		if got := columnValue(t, repo, nil, "cl_stale", issues[j]); got != want {
+			t.Errorf("#%d: cl_stale = %q; want %q", issues[j].Number, got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		flag string
+		want []int32
+	}{
+		{"stale-cl=true", []int32{1}},
+		{"stale-cl=false", []int32{2, 3}},
+	} {
+		restore := withFlags(t, "cl-stale=168h", tt.flag)
+		if got := included(issues...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("with -%s: included %v; want %v", tt.flag, got, tt.want)
+		}
+		restore()
+	}
+}
+
+func TestCLReviewLatency(t *testing.T) {
+	defer withFlags(t)()
+
+	day := func(n int) time.Time { return testNow.AddDate(0, 0, n) }
+	repo, refs := newTestCLs(t, testIssues(1, 2, 3, 4),
+		testCL{number: 101, fixes: []int32{1}, created: day(-10), votes: []testVote{
+			{day(-8), "Code-Review=+1"},
+			{day(-7), "Code-Review=+2"},
+			{day(-6), "Run-TryBot=+1"},
+		}},
+		// The newest live CL counts, even if an older one was reviewed.
+		testCL{number: 201, fixes: []int32{2}, created: day(-20), votes: []testVote{{day(-19), "Code-Review=+1"}}},
+		testCL{number: 202, fixes: []int32{2}, created: day(-10), votes: []testVote{{day(-5), "Code-Review=+1"}}},
+		// A CL without a review vote has no latency yet.
+		testCL{number: 301, fixes: []int32{3}, created: day(-10), votes: []testVote{{day(-9), "Run-TryBot=+1"}}},
+	)
+
+	for n, want := range map[int32]string{1: "3", 2: "5", 3: "", 4: ""} {
+		i := newIssue(repo.Issue(n), refs[n])
+		if got := columnValue(t, repo, nil, "cl_review_latency_days", i); got != want {
+			t.Errorf("#%d: cl_review_latency_days = %q; want %q", n, got, want)
+		}
+	}
+}
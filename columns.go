@@ -0,0 +1,463 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/build/maintner"
+)
+
+// A labelValues is a flag.Value mapping label names to numbers,
+// set from a comma-separated list of name=value pairs.
+type labelValues map[string]float64
+
+func (m labelValues) String() string {
+	var pairs []string
+	for name, v := range m {
+		pairs = append(pairs, name+"="+strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m labelValues) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			return fmt.Errorf("missing '=' in %q", pair)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(pair[i+1:]), 64)
+		if err != nil {
+			return fmt.Errorf("invalid value in %q", pair)
+		}
+		m[strings.TrimSpace(pair[:i])] = v
+	}
+	return nil
+}
+
+// sizeLabels maps the names of labels that estimate the size of an issue to
+// the corresponding effort, as set by -size-labels.
+var sizeLabels = labelValues{}
+
+func init() {
+	flag.Var(sizeLabels, "size-labels", "comma-separated label=effort pairs (such as Size-S=1,Size-L=5); adds an effort column from each issue's size label")
+}
+
+// ageUnitDays maps each unit accepted by -age-units to its length in days.
+// A month is an average Gregorian month, so that ages in months are consistent
+// regardless of the calendar dates involved.
+var ageUnitDays = map[string]float64{
+	"days":   1,
+	"weeks":  7,
+	"months": 365.2425 / 12,
+}
+
+const dateFormat = "2006-01-02"
+
+// A column is one field of the exported records.
+type column struct {
+	name  string
+	value func(*issue) string
+}
+
+// defaultColumns are the columns exported by -mode=issues if -columns is not
+// set. They are the columns of the original export, in the same order, so that
+// existing consumers continue to work; other columns must be requested.
+const defaultColumns = "number,updated,state,when,who,title"
+
+// columns returns the columns to export, as selected by the command-line flags.
+// If -columns is not set, columns returns the comma-separated columns in
+// defaults followed by any columns enabled by their own flags (such as
+// -assignee-stale).
+func columns(repo *maintner.GitHubRepo, c *census, defaults string) ([]column, error) {
+	all, enabled, err := availableColumns(repo, c)
+	if err != nil {
+		return nil, err
+	}
+	names := *columnNames
+	if names == "" {
+		names = defaults
+		for _, col := range enabled {
+			names += "," + col.name
+		}
+	}
+	return selectColumns(all, names)
+}
+
+// selectColumns returns the columns in cols named in the comma-separated list
+// names, in the order listed.
+func selectColumns(cols []column, names string) ([]column, error) {
+	var selected []column
+	for _, name := range strings.Split(names, ",") {
+		col, ok := findColumn(cols, strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q (some columns require a flag to enable them)", name)
+		}
+		selected = append(selected, col)
+	}
+	return selected, nil
+}
+
+// findColumn returns the column in cols with the given name.
+func findColumn(cols []column, name string) (column, bool) {
+	for _, col := range cols {
+		if col.name == name {
+			return col, true
+		}
+	}
+	return column{}, false
+}
+
+// availableColumns returns every column that can be exported with the current
+// flags. enabled is the subset of those columns that are available only
+// because a flag enabling them was set.
+func availableColumns(repo *maintner.GitHubRepo, c *census) (all, enabled []column, err error) {
+	botSet := map[string]bool{}
+	for _, login := range strings.Split(*bots, ",") {
+		if login = strings.TrimSpace(login); login != "" {
+			botSet[login] = true
+		}
+	}
+
+	cols := []column{
+		{"key", func(i *issue) string { return fmt.Sprintf("%s#%d", repo.ID(), i.Number) }},
+		{"number", func(i *issue) string { return strconv.FormatInt(int64(i.Number), 10) }},
+		{"updated", func(i *issue) string { return i.Updated.Format(dateFormat) }},
+		{"state", func(i *issue) string { return i.state }},
+		{"state_age_days", func(i *issue) string {
+			since := stateSince(i)
+			if since.IsZero() {
+				return ""
+			}
+			return daysSince(since)
+		}},
+		{"when", func(i *issue) string { return i.when }},
+		{"when_rank", func(i *issue) string {
+			if r := i.whenRank(); r > 0 {
+				return strconv.Itoa(r)
+			}
+			return ""
+		}},
+		{"who", func(i *issue) string { return displayLogins(i.who) }},
+		{"assignee_open_load", func(i *issue) string {
+			if len(i.who) == 0 {
+				return ""
+			}
+			return strconv.Itoa(c.assigned[i.who[0]])
+		}},
+		{"first_assigned_at", func(i *issue) string {
+			first := firstEvent(i.GitHubIssue, "assigned")
+			if first.IsZero() {
+				return ""
+			}
+			return first.Format(dateFormat)
+		}},
+		{"assignee_changes", func(i *issue) string {
+			n, ok := countEvents(i.GitHubIssue, "assigned", "unassigned")
+			if !ok {
+				return ""
+			}
+			return strconv.Itoa(n)
+		}},
+		{"title", func(i *issue) string { return i.Title }},
+		{"title_len", func(i *issue) string { return strconv.Itoa(utf8.RuneCountInString(i.Title)) }},
+		{"cls", func(i *issue) string { return strconv.Itoa(i.cls.count()) }},
+		{"has_fix_cl", func(i *issue) string { return strconv.FormatBool(i.cls != nil && i.cls.hasFix) }},
+		{"has_test_cl", func(i *issue) string { return strconv.FormatBool(i.cls != nil && i.cls.hasTest) }},
+		{"cl_review_latency_days", func(i *issue) string {
+			cl := i.cls.newestLive()
+			if cl == nil {
+				return ""
+			}
+			vote := lastReviewVote(cl)
+			if vote.IsZero() {
+				return ""
+			}
+			return strconv.Itoa(int(vote.Sub(cl.Created).Hours() / 24))
+		}},
+		{"open_cls", func(i *issue) string { return i.cls.numbers("new", "draft") }},
+		{"merged_cls", func(i *issue) string { return i.cls.numbers("merged") }},
+		{"abandoned_cls", func(i *issue) string { return i.cls.numbers("abandoned") }},
+		{"labeled_recently", func(i *issue) string {
+			labeled, ok := labeledSince(i.GitHubIssue, now.Add(-*recent))
+			if !ok {
+				return ""
+			}
+			return strconv.FormatBool(labeled)
+		}},
+		{"age_" + *ageUnits, func(i *issue) string {
+			days := now.Sub(i.Created).Hours() / 24
+			return strconv.Itoa(int(days / ageUnitDays[*ageUnits]))
+		}},
+		{"untriaged_days", func(i *issue) string {
+			if i.Closed || i.triaged() {
+				return ""
+			}
+			return daysSince(i.Created)
+		}},
+		{"days_since_human_comment", func(i *issue) string {
+			// Bots comment on many issues automatically, which shouldn't make
+			// the issue look less stale.
+			last := lastCommentExcept(i.GitHubIssue, botSet)
+			if last.IsZero() {
+				return ""
+			}
+			return daysSince(last)
+		}},
+		{"comments_last_30d", func(i *issue) string {
+			return strconv.Itoa(commentsSince(i.GitHubIssue, now.Add(-*commentWindow)))
+		}},
+		{"discussion_heat", func(i *issue) string {
+			// The heat is meant to combine recent comments and reactions,
+			// but maintner does not mirror reactions, so for now only the
+			// comments contribute.
+			n := commentsSince(i.GitHubIssue, now.Add(-*commentWindow))
+			return strconv.FormatFloat(*heatCommentWeight*float64(n), 'g', -1, 64)
+		}},
+		{"opened_recently", func(i *issue) string {
+			return strconv.FormatBool(!i.Created.Before(now.Add(-*recent)))
+		}},
+		{"reopened_count", func(i *issue) string {
+			n, ok := countEvents(i.GitHubIssue, "reopened")
+			if !ok {
+				return ""
+			}
+			return strconv.Itoa(n)
+		}},
+		{"resolution_bucket", func(i *issue) string {
+			if !i.Closed || i.ClosedAt.IsZero() {
+				return ""
+			}
+			return resolutionBucket(i.ClosedAt.Sub(i.Created))
+		}},
+		{"close_quarter", func(i *issue) string {
+			if !i.Closed || i.ClosedAt.IsZero() {
+				return ""
+			}
+			t := i.ClosedAt.UTC()
+			return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())+2)/3)
+		}},
+		{"blocks_release", func(i *issue) string {
+			m := milestone(i.GitHubIssue)
+			return strconv.FormatBool(i.HasLabelID(releaseBlockerID) && m != nil && c.cycle != "" && m.Title == c.cycle)
+		}},
+		{"milestone_pct", func(i *issue) string {
+			m := milestone(i.GitHubIssue)
+			if m == nil {
+				return ""
+			}
+			mc := c.milestones[m.ID]
+			pct := 100 * float64(mc.closed) / float64(mc.open+mc.closed)
+			return strconv.FormatFloat(pct, 'f', 1, 64)
+		}},
+		{"milestone_open", func(i *issue) string {
+			m := milestone(i.GitHubIssue)
+			if m == nil {
+				return ""
+			}
+			return strconv.Itoa(c.milestones[m.ID].open)
+		}},
+		{"has_milestone", func(i *issue) string { return strconv.FormatBool(milestone(i.GitHubIssue) != nil) }},
+		{"milestone_is_open", func(i *issue) string {
+			m := milestone(i.GitHubIssue)
+			if m == nil {
+				return ""
+			}
+			return strconv.FormatBool(!m.Closed)
+		}},
+		{"label_count", func(i *issue) string { return strconv.Itoa(len(i.Labels)) }},
+		{"is_help_wanted", func(i *issue) string { return strconv.FormatBool(i.HasLabelID(helpWantedID)) }},
+		{"is_unreleased", func(i *issue) string {
+			m := milestone(i.GitHubIssue)
+			return strconv.FormatBool(m != nil && m.Number == unreleasedMilestone)
+		}},
+	}
+
+	if *assigneeStale > 0 {
+		// Updated includes any activity on the issue, not just activity by the
+		// assignees; that's cheap to compute and good enough to spot tasks
+		// that have been dropped entirely.
+		enabled = append(enabled, column{"assignee_stale", func(i *issue) string {
+			if len(i.who) == 0 || i.Closed {
+				return ""
+			}
+			return strconv.FormatBool(now.Sub(i.Updated) >= *assigneeStale)
+		}})
+	}
+
+	if *triageSLA > 0 {
+		// A breach is reported only while it is ongoing: an issue that was
+		// triaged late is not distinguished from one triaged promptly.
+		enabled = append(enabled, column{"sla_breach", func(i *issue) string {
+			if i.Closed {
+				return ""
+			}
+			return strconv.FormatBool(!i.triaged() && now.Sub(i.Created) > *triageSLA)
+		}})
+	}
+
+	if *untriagedTitleRE != "" {
+		re, err := regexp.Compile(*untriagedTitleRE)
+		if err != nil {
+			return nil, nil, fmt.Errorf("-untriaged-title-regexp: %v", err)
+		}
+		enabled = append(enabled, column{"title_needs_triage", func(i *issue) string {
+			return strconv.FormatBool(re.MatchString(i.Title))
+		}})
+	}
+
+	if *clStale > 0 {
+		enabled = append(enabled, column{"cl_stale", func(i *issue) string {
+			stale, ok := i.cls.stale(*clStale)
+			if !ok {
+				return ""
+			}
+			return strconv.FormatBool(stale)
+		}})
+	}
+
+	if *assigneeActivity {
+		enabled = append(enabled, column{"assignee_last_active", func(i *issue) string {
+			if len(i.who) == 0 {
+				return ""
+			}
+			last := lastActivityBy(i.GitHubIssue, i.who)
+			if last.IsZero() {
+				return ""
+			}
+			return last.Format(dateFormat)
+		}})
+	}
+
+	if *extractRefs {
+		self := repo.ID().String()
+		enabled = append(enabled, column{"external_refs", func(i *issue) string {
+			return strings.Join(externalRefs(i.Body, self), ",")
+		}})
+	}
+
+	if teams != nil {
+		// Team membership is a property of the primary (first) assignee.
+		enabled = append(enabled, column{"team", func(i *issue) string {
+			if len(i.who) == 0 {
+				return ""
+			}
+			if team, ok := teams[i.who[0]]; ok {
+				return team
+			}
+			return "(none)"
+		}})
+	}
+
+	if len(sizeLabels) > 0 {
+		enabled = append(enabled, column{"effort", func(i *issue) string {
+			// If an issue carries more than one size label, report the
+			// largest.
+			effort, found := 0.0, false
+			for _, l := range i.Labels {
+				if v, ok := sizeLabels[l.Name]; ok && (!found || v > effort) {
+					effort, found = v, true
+				}
+			}
+			if !found {
+				return ""
+			}
+			return strconv.FormatFloat(effort, 'g', -1, 64)
+		}})
+	}
+
+	if *isoWeek {
+		enabled = append(enabled,
+			column{"created_week", func(i *issue) string { return formatISOWeek(i.Created) }},
+			column{"updated_week", func(i *issue) string { return formatISOWeek(i.Updated) }},
+		)
+	}
+
+	if *whenMilestoneMismatch {
+		enabled = append(enabled, column{"milestone_when", func(i *issue) string { return i.milestoneWhen }})
+	}
+
+	if *includeFrozen {
+		enabled = append(enabled, column{"is_frozen", func(i *issue) string { return strconv.FormatBool(frozen(i.GitHubIssue)) }})
+	}
+
+	if *withSnapshot {
+		snapshot := c.snapshot.UTC().Format(time.RFC3339)
+		enabled = append(enabled, column{"snapshot_at", func(*issue) string { return snapshot }})
+	}
+
+	return append(cols, enabled...), enabled, nil
+}
+
+// daysSince formats the number of whole days from t until now.
+func daysSince(t time.Time) string {
+	return strconv.Itoa(int(now.Sub(t).Hours() / 24))
+}
+
+// issueRefRE matches references to GitHub issues in other repositories,
+// such as "golang/vscode-go#123". The reference must not follow a path or
+// domain character, so that "golang.org/x/tools#123" does not match as
+// "x/tools#123".
+var issueRefRE = regexp.MustCompile(`(?:^|[^\w./-])(([A-Za-z0-9][A-Za-z0-9-]*)/([A-Za-z0-9_.-]+)#[0-9]+)\b`)
+
+// externalRefs returns the distinct references in text to issues in
+// repositories other than self, in order of first appearance.
+func externalRefs(text, self string) []string {
+	var refs []string
+	seen := map[string]bool{}
+	for _, m := range issueRefRE.FindAllStringSubmatch(text, -1) {
+		ref := m[1]
+		if m[2]+"/"+m[3] == self || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// resolutionBucket returns a coarse category for the time d taken to close
+// an issue.
+func resolutionBucket(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d < day:
+		return "same-day"
+	case d < 7*day:
+		return "<week"
+	case d < 30*day:
+		return "<month"
+	case d < 91*day:
+		return "<quarter"
+	default:
+		return "longer"
+	}
+}
+
+// formatISOWeek formats the ISO 8601 week containing t, such as "2020-W01".
+func formatISOWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// header returns the names of cols.
+func header(cols []column) []string {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.name
+	}
+	return names
+}
@@ -0,0 +1,929 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/build/maintner"
+	"golang.org/x/build/maintner/maintpb"
+)
+
+// columnValue returns the value of the named column for i with the current
+// flags, failing the test if no such column is available.
+func columnValue(t *testing.T, repo *maintner.GitHubRepo, c *census, name string, i *issue) string {
+	t.Helper()
+	cols, _, err := availableColumns(repo, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	col, ok := findColumn(cols, name)
+	if !ok {
+		t.Fatalf("no column %q", name)
+	}
+	return col.value(i)
+}
+
+func TestAssigneeStale(t *testing.T) {
+	defer withFlags(t, "assignee-stale=720h")()
+
+	gopher := &maintner.GitHubUser{ID: 1, Login: "gopher"}
+	window := 720 * time.Hour
+	tests := []struct {
+		desc string
+		gi   *maintner.GitHubIssue
+		want string
+	}{
+		{
+			desc: "updated exactly one window ago",
+			gi:   &maintner.GitHubIssue{Number: 1, Assignees: []*maintner.GitHubUser{gopher}, Updated: now.Add(-window)},
+			want: "true",
+		},
+		{
+			desc: "updated just within the window",
+			gi:   &maintner.GitHubIssue{Number: 2, Assignees: []*maintner.GitHubUser{gopher}, Updated: now.Add(-window + time.Second)},
+			want: "false",
+		},
+		{
+			desc: "unassigned",
+			gi:   &maintner.GitHubIssue{Number: 3, Updated: now.Add(-2 * window)},
+			want: "",
+		},
+		{
+			desc: "closed",
+			gi:   &maintner.GitHubIssue{Number: 4, Closed: true, Assignees: []*maintner.GitHubUser{gopher}, Updated: now.Add(-2 * window)},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		got := columnValue(t, nil, nil, "assignee_stale", newIssue(tt.gi, nil))
+		if got != tt.want {
+			t.Errorf("%s: assignee_stale = %q; want %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestExternalRefs(t *testing.T) {
+	const body = `Reported downstream as golang/vscode-go#123 and
+GoogleCloudPlatform/google-cloud-go#481 (see also golang/vscode-go#123).
+
+Not references: golang/go#42 is this repo, and golang.org/x/tools#123 and
+https://example.com/a/b#7 are paths.`
+
+	got := externalRefs(body, "golang/go")
+	want := []string{"golang/vscode-go#123", "GoogleCloudPlatform/google-cloud-go#481"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("externalRefs(…) = %q; want %q", got, want)
+	}
+}
+
+func TestLabeledRecently(t *testing.T) {
+	defer withFlags(t, "recent=168h")()
+
+	labeled := func(id int64, at time.Time) *maintpb.GithubIssueEvent {
+		return &maintpb.GithubIssueEvent{Id: id, EventType: "labeled", Created: ts(at), Label: &maintpb.GithubLabel{Name: "NeedsFix"}}
+	}
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{Number: 1, Created: ts(now.AddDate(0, -1, 0)), Event: []*maintpb.GithubIssueEvent{
+			labeled(101, now.AddDate(0, 0, -20)),
+			labeled(102, now.AddDate(0, 0, -2)),
+		}},
+		&maintpb.GithubIssueMutation{Number: 2, Created: ts(now.AddDate(0, -1, 0)), Event: []*maintpb.GithubIssueEvent{
+			labeled(201, now.AddDate(0, 0, -20)),
+			{Id: 202, EventType: "closed", Created: ts(now.AddDate(0, 0, -1))},
+		}},
+		&maintpb.GithubIssueMutation{Number: 3, Created: ts(now.AddDate(0, -1, 0))},
+	)
+
+	for n, want := range map[int32]string{1: "true", 2: "false", 3: ""} {
+		got := columnValue(t, repo, nil, "labeled_recently", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: labeled_recently = %q; want %q", n, got, want)
+		}
+	}
+}
+
+// inMilestone returns a mutation for an open or closed issue in the
+// Go1.13 milestone.
+func inMilestone(number int32, closed bool) *maintpb.GithubIssueMutation {
+	return &maintpb.GithubIssueMutation{
+		Number:         number,
+		Created:        ts(testNow.AddDate(0, -1, 0)),
+		Updated:        ts(testNow.AddDate(0, 0, -1)),
+		MilestoneId:    500,
+		MilestoneNum:   50,
+		MilestoneTitle: "Go1.13",
+		Closed:         &maintpb.BoolChange{Val: closed},
+	}
+}
+
+func TestMilestonePct(t *testing.T) {
+	defer withFlags(t)()
+
+	repo := newTestRepo(t,
+		inMilestone(1, false),
+		inMilestone(2, false),
+		inMilestone(3, false),
+		inMilestone(4, true),
+		&maintpb.GithubIssueMutation{Number: 5, Created: ts(now), NoMilestone: true},
+	)
+	c := takeCensus(repo)
+
+	for n, want := range map[int32]string{1: "25.0", 4: "25.0", 5: ""} {
+		got := columnValue(t, repo, c, "milestone_pct", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: milestone_pct = %q; want %q", n, got, want)
+		}
+	}
+}
+
+func TestAgeUnits(t *testing.T) {
+	tests := []struct {
+		units string
+		days  int
+		want  string
+	}{
+		{"days", 70, "70"},
+		{"weeks", 70, "10"},
+		{"weeks", 69, "9"},
+		{"months", 70, "2"},
+		{"months", 365, "11"}, // 365 days is just short of 12 average months.
+		{"months", 366, "12"},
+	}
+	for _, tt := range tests {
+		restore := withFlags(t, "age-units="+tt.units)
+		gi := &maintner.GitHubIssue{Number: 1, Created: now.AddDate(0, 0, -tt.days)}
+		got := columnValue(t, nil, nil, "age_"+tt.units, newIssue(gi, nil))
+		if got != tt.want {
+			t.Errorf("age_%s for %d days = %q; want %q", tt.units, tt.days, got, tt.want)
+		}
+		restore()
+	}
+}
+
+func TestIsUnreleased(t *testing.T) {
+	defer withFlags(t)()
+
+	for _, tt := range []struct {
+		m    *maintner.GitHubMilestone
+		want string
+	}{
+		{&maintner.GitHubMilestone{ID: 1, Number: unreleasedMilestone, Title: "Unreleased"}, "true"},
+		{&maintner.GitHubMilestone{ID: 2, Number: unplannedMilestone, Title: "Unplanned"}, "false"},
+		{nil, "false"},
+	} {
+		gi := &maintner.GitHubIssue{Number: 1, Milestone: tt.m}
+		if got := columnValue(t, nil, nil, "is_unreleased", newIssue(gi, nil)); got != tt.want {
+			t.Errorf("is_unreleased in %v = %q; want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	defer withFlags(t)()
+
+	repo := newTestRepo(t, &maintpb.GithubIssueMutation{Number: 12345, Created: ts(now)})
+	got := columnValue(t, repo, nil, "key", newIssue(repo.Issue(12345), nil))
+	if want := "golang/go#12345"; got != want {
+		t.Errorf("key = %q; want %q", got, want)
+	}
+}
+
+func TestOpenedRecently(t *testing.T) {
+	defer withFlags(t, "recent=168h")()
+
+	for _, tt := range []struct {
+		age  time.Duration
+		want string
+	}{
+		{168 * time.Hour, "true"},
+		{168*time.Hour + time.Second, "false"},
+		{0, "true"},
+	} {
+		gi := &maintner.GitHubIssue{Number: 1, Created: now.Add(-tt.age)}
+		if got := columnValue(t, nil, nil, "opened_recently", newIssue(gi, nil)); got != tt.want {
+			t.Errorf("opened_recently for age %v = %q; want %q", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestUntriagedDays(t *testing.T) {
+	defer withFlags(t)()
+
+	created := now.AddDate(0, 0, -400)
+	needsFix := map[int64]*maintner.GitHubLabel{needsFixID: {ID: needsFixID, Name: "NeedsFix"}}
+	unplanned := &maintner.GitHubMilestone{ID: 1, Number: unplannedMilestone, Title: "Unplanned"}
+	for _, tt := range []struct {
+		desc string
+		gi   *maintner.GitHubIssue
+		want string
+	}{
+		{"untriaged", &maintner.GitHubIssue{Number: 1, Created: created}, "400"},
+		{"labeled NeedsFix", &maintner.GitHubIssue{Number: 2, Created: created, Labels: needsFix}, ""},
+		{"milestoned", &maintner.GitHubIssue{Number: 3, Created: created, Milestone: unplanned}, ""},
+		{"closed", &maintner.GitHubIssue{Number: 4, Created: created, Closed: true}, ""},
+	} {
+		if got := columnValue(t, nil, nil, "untriaged_days", newIssue(tt.gi, nil)); got != tt.want {
+			t.Errorf("%s: untriaged_days = %q; want %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestAssigneeLastActive(t *testing.T) {
+	defer withFlags(t, "assignee-activity")()
+
+	gopher := &maintpb.GithubUser{Id: 10, Login: "gopher"}
+	other := &maintpb.GithubUser{Id: 11, Login: "other"}
+	day := func(n int) time.Time { return now.AddDate(0, 0, n) }
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{
+			Number:    1,
+			Created:   ts(day(-30)),
+			User:      other,
+			Assignees: []*maintpb.GithubUser{gopher},
+			Event: []*maintpb.GithubIssueEvent{
+				{Id: 101, EventType: "labeled", ActorId: gopher.Id, Created: ts(day(-5)), Label: &maintpb.GithubLabel{Name: "NeedsFix"}},
+				{Id: 102, EventType: "labeled", ActorId: other.Id, Created: ts(day(-1)), Label: &maintpb.GithubLabel{Name: "Soon"}},
+			},
+			Comment: []*maintpb.GithubIssueCommentMutation{
+				{Id: 201, User: gopher, Created: ts(day(-3)), Body: "Looking into it."},
+				{Id: 202, User: other, Created: ts(day(-2)), Body: "Any news?"},
+			},
+		},
+		&maintpb.GithubIssueMutation{
+			Number:    2,
+			Created:   ts(day(-30)),
+			Assignees: []*maintpb.GithubUser{gopher},
+			Event: []*maintpb.GithubIssueEvent{
+				{Id: 301, EventType: "labeled", ActorId: other.Id, Created: ts(day(-1)), Label: &maintpb.GithubLabel{Name: "Soon"}},
+			},
+		},
+		&maintpb.GithubIssueMutation{Number: 3, Created: ts(day(-30))},
+	)
+
+	for n, want := range map[int32]string{1: day(-3).Format(dateFormat), 2: "", 3: ""} {
+		got := columnValue(t, repo, nil, "assignee_last_active", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: assignee_last_active = %q; want %q", n, got, want)
+		}
+	}
+}
+
+func TestReopenedCount(t *testing.T) {
+	defer withFlags(t)()
+
+	event := func(id int64, typ string, days int) *maintpb.GithubIssueEvent {
+		return &maintpb.GithubIssueEvent{Id: id, EventType: typ, Created: ts(now.AddDate(0, 0, days))}
+	}
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{Number: 1, Created: ts(now.AddDate(0, -1, 0)), Event: []*maintpb.GithubIssueEvent{
+			event(101, "closed", -20),
+			event(102, "reopened", -15),
+			event(103, "closed", -10),
+			event(104, "reopened", -5),
+		}},
+		&maintpb.GithubIssueMutation{Number: 2, Created: ts(now.AddDate(0, -1, 0)), Event: []*maintpb.GithubIssueEvent{
+			event(201, "closed", -20),
+		}},
+		&maintpb.GithubIssueMutation{Number: 3, Created: ts(now.AddDate(0, -1, 0))},
+	)
+
+	for n, want := range map[int32]string{1: "2", 2: "0", 3: ""} {
+		got := columnValue(t, repo, nil, "reopened_count", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: reopened_count = %q; want %q", n, got, want)
+		}
+	}
+}
+
+func TestSnapshotAt(t *testing.T) {
+	defer withFlags(t, "with-snapshot")()
+
+	snapshot := time.Date(2019, time.April, 30, 17, 4, 5, 0, time.UTC)
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{Number: 1, Created: ts(snapshot.AddDate(0, -1, 0)), Updated: ts(snapshot.Add(-time.Hour))},
+		// Pull requests are not exported, but their updates are still data in the corpus.
+		&maintpb.GithubIssueMutation{Number: 2, Created: ts(snapshot.AddDate(0, -1, 0)), Updated: ts(snapshot), PullRequest: true},
+	)
+	c := takeCensus(repo)
+	got := columnValue(t, repo, c, "snapshot_at", newIssue(repo.Issue(1), nil))
+	if want := "2019-04-30T17:04:05Z"; got != want {
+		t.Errorf("snapshot_at = %q; want %q", got, want)
+	}
+}
+
+func TestMilestoneOpen(t *testing.T) {
+	defer withFlags(t)()
+
+	repo := newTestRepo(t,
+		inMilestone(1, false),
+		inMilestone(2, false),
+		inMilestone(3, true),
+		&maintpb.GithubIssueMutation{Number: 4, Created: ts(now), NoMilestone: true},
+	)
+	c := takeCensus(repo)
+
+	for n, want := range map[int32]string{1: "2", 3: "2", 4: ""} {
+		got := columnValue(t, repo, c, "milestone_open", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: milestone_open = %q; want %q", n, got, want)
+		}
+	}
+}
+
+func TestFormatISOWeek(t *testing.T) {
+	for _, tt := range []struct {
+		date string
+		want string
+	}{
+		{"2019-12-29", "2019-W52"},
+		{"2019-12-30", "2020-W01"}, // Monday of the first week of 2020.
+		{"2021-01-03", "2020-W53"}, // Sunday of the last week of 2020.
+		{"2021-01-04", "2021-W01"},
+	} {
+		d, err := time.Parse(dateFormat, tt.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := formatISOWeek(d); got != tt.want {
+			t.Errorf("formatISOWeek(%s) = %q; want %q", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestStateAgeDays(t *testing.T) {
+	defer withFlags(t)()
+
+	day := func(n int) time.Time { return now.AddDate(0, 0, n) }
+	labeled := func(id int64, name string, at time.Time) *maintpb.GithubIssueEvent {
+		return &maintpb.GithubIssueEvent{Id: id, EventType: "labeled", Created: ts(at), Label: &maintpb.GithubLabel{Name: name}}
+	}
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{
+			Number:   1,
+			Created:  ts(day(-30)),
+			Closed:   &maintpb.BoolChange{Val: true},
+			ClosedAt: ts(day(-10)),
+		},
+		&maintpb.GithubIssueMutation{
+			Number:   2,
+			Created:  ts(day(-30)),
+			AddLabel: []*maintpb.GithubLabel{{Id: needsDecisionID, Name: "NeedsDecision"}, {Id: documentationID, Name: "Documentation"}},
+			Event: []*maintpb.GithubIssueEvent{
+				labeled(201, "NeedsDecision", day(-8)),
+				labeled(202, "NeedsDecision", day(-4)),
+				labeled(203, "Documentation", day(-2)),
+			},
+		},
+		// A deciding issue without events: the state's start can't be inferred.
+		&maintpb.GithubIssueMutation{
+			Number:   3,
+			Created:  ts(day(-30)),
+			AddLabel: []*maintpb.GithubLabel{{Id: needsDecisionID, Name: "NeedsDecision"}},
+		},
+	)
+
+	for n, want := range map[int32]string{1: "10", 2: "4", 3: ""} {
+		i := newIssue(repo.Issue(n), nil)
+		if got := columnValue(t, repo, nil, "state_age_days", i); got != want {
+			t.Errorf("#%d (%s): state_age_days = %q; want %q", n, i.state, got, want)
+		}
+	}
+}
+
+func TestTitleLen(t *testing.T) {
+	defer withFlags(t)()
+
+	for _, tt := range []struct {
+		title string
+		want  string
+	}{
+		{"", "0"},
+		{"cmd/go: fail", "12"},
+		{"x/text: mishandles “é” and 世界", "29"},
+	} {
+		gi := &maintner.GitHubIssue{Number: 1, Title: tt.title}
+		if got := columnValue(t, nil, nil, "title_len", newIssue(gi, nil)); got != tt.want {
+			t.Errorf("title_len for %q = %q; want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestResolutionBucket(t *testing.T) {
+	defer withFlags(t)()
+
+	const day = 24 * time.Hour
+	created := now.AddDate(-1, 0, 0)
+	for _, tt := range []struct {
+		closed bool
+		d      time.Duration
+		want   string
+	}{
+		{false, 0, ""},
+		{true, 3 * time.Hour, "same-day"},
+		{true, day, "<week"},
+		{true, 6 * day, "<week"},
+		{true, 7 * day, "<month"},
+		{true, 29 * day, "<month"},
+		{true, 30 * day, "<quarter"},
+		{true, 90 * day, "<quarter"},
+		{true, 91 * day, "longer"},
+		{true, 200 * day, "longer"},
+	} {
+		gi := &maintner.GitHubIssue{Number: 1, Created: created}
+		if tt.closed {
+			gi.Closed = true
+			gi.ClosedAt = created.Add(tt.d)
+		}
+		if got := columnValue(t, nil, nil, "resolution_bucket", newIssue(gi, nil)); got != tt.want {
+			t.Errorf("resolution_bucket (closed %v, after %v) = %q; want %q", tt.closed, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestDaysSinceHumanComment(t *testing.T) {
+	defer withFlags(t)()
+
+	human := &maintpb.GithubUser{Id: 10, Login: "gopher"}
+	bot := &maintpb.GithubUser{Id: 11, Login: "gopherbot"}
+	day := func(n int) time.Time { return now.AddDate(0, 0, n) }
+	comment := func(id int64, u *maintpb.GithubUser, days int) *maintpb.GithubIssueCommentMutation {
+		return &maintpb.GithubIssueCommentMutation{Id: id, User: u, Created: ts(day(days)), Body: "comment"}
+	}
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{Number: 1, Created: ts(day(-30)), Comment: []*maintpb.GithubIssueCommentMutation{
+			comment(101, human, -20),
+			comment(102, bot, -15),
+			comment(103, human, -10),
+			comment(104, bot, -2),
+		}},
+		&maintpb.GithubIssueMutation{Number: 2, Created: ts(day(-30)), Comment: []*maintpb.GithubIssueCommentMutation{
+			comment(201, bot, -5),
+		}},
+		&maintpb.GithubIssueMutation{Number: 3, Created: ts(day(-30))},
+	)
+
+	for n, want := range map[int32]string{1: "10", 2: "", 3: ""} {
+		got := columnValue(t, repo, nil, "days_since_human_comment", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: days_since_human_comment = %q; want %q", n, got, want)
+		}
+	}
+
+	// With -bots overridden, gopherbot's comments count as human.
+	defer withFlags(t, "bots=gobot")()
+	if got := columnValue(t, repo, nil, "days_since_human_comment", newIssue(repo.Issue(1), nil)); got != "2" {
+		t.Errorf("with -bots=gobot, #1: days_since_human_comment = %q; want %q", got, "2")
+	}
+}
+
+func TestFirstAssignedAt(t *testing.T) {
+	defer withFlags(t)()
+
+	gopher := &maintpb.GithubUser{Id: 10, Login: "gopher"}
+	day := func(n int) time.Time { return now.AddDate(0, 0, n) }
+	event := func(id int64, typ string, days int) *maintpb.GithubIssueEvent {
+		return &maintpb.GithubIssueEvent{Id: id, EventType: typ, AssigneeId: gopher.Id, Created: ts(day(days))}
+	}
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{Number: 1, Created: ts(day(-30)), Assignees: []*maintpb.GithubUser{gopher}, Event: []*maintpb.GithubIssueEvent{
+			event(101, "assigned", -20),
+			event(102, "unassigned", -15),
+			event(103, "assigned", -10),
+		}},
+		// Assigned when created, without an event recording it.
+		&maintpb.GithubIssueMutation{Number: 2, Created: ts(day(-30)), Assignees: []*maintpb.GithubUser{gopher}},
+		&maintpb.GithubIssueMutation{Number: 3, Created: ts(day(-30))},
+	)
+
+	for n, want := range map[int32]string{1: day(-20).Format(dateFormat), 2: "", 3: ""} {
+		got := columnValue(t, repo, nil, "first_assigned_at", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: first_assigned_at = %q; want %q", n, got, want)
+		}
+	}
+}
+
+func TestIsHelpWanted(t *testing.T) {
+	defer withFlags(t)()
+
+	help := map[int64]*maintner.GitHubLabel{helpWantedID: {ID: helpWantedID, Name: "help wanted"}}
+	unplanned := &maintner.GitHubMilestone{ID: 1, Number: unplannedMilestone, Title: "Unplanned"}
+	go113 := &maintner.GitHubMilestone{ID: 500, Number: 50, Title: "Go1.13"}
+	for _, tt := range []struct {
+		m      *maintner.GitHubMilestone
+		labels map[int64]*maintner.GitHubLabel
+		want   string
+		when   string
+	}{
+		{go113, help, "true", ""},
+		{unplanned, help, "true", "help"},
+		{nil, help, "true", ""},
+		{unplanned, nil, "false", "unplanned"},
+	} {
+		i := newIssue(&maintner.GitHubIssue{Number: 1, Milestone: tt.m, Labels: tt.labels}, nil)
+		if got := columnValue(t, nil, nil, "is_help_wanted", i); got != tt.want {
+			t.Errorf("is_help_wanted in %v with labels %v = %q; want %q", tt.m, tt.labels, got, tt.want)
+		}
+		if got := columnValue(t, nil, nil, "when", i); got != tt.when {
+			t.Errorf("when in %v with labels %v = %q; want %q", tt.m, tt.labels, got, tt.when)
+		}
+	}
+}
+
+func TestAssigneeOpenLoad(t *testing.T) {
+	defer withFlags(t)()
+
+	alice := &maintpb.GithubUser{Id: 10, Login: "alice"}
+	bob := &maintpb.GithubUser{Id: 11, Login: "bob"}
+	issue := func(number int32, closed bool, assignees ...*maintpb.GithubUser) *maintpb.GithubIssueMutation {
+		return &maintpb.GithubIssueMutation{
+			Number:    number,
+			Created:   ts(now.AddDate(0, -1, 0)),
+			Assignees: assignees,
+			Closed:    &maintpb.BoolChange{Val: closed},
+		}
+	}
+	repo := newTestRepo(t,
+		issue(1, false, alice),
+		issue(2, false, alice, bob),
+		issue(3, false, alice),
+		issue(4, true, alice), // Closed issues don't count toward the load.
+		issue(5, true, bob),
+		issue(6, false, bob, alice),
+		issue(7, false),
+	)
+	c := takeCensus(repo)
+
+	for n, want := range map[int32]string{1: "4", 2: "4", 4: "4", 5: "2", 6: "2", 7: ""} {
+		got := columnValue(t, repo, c, "assignee_open_load", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: assignee_open_load = %q; want %q", n, got, want)
+		}
+	}
+}
+
+func TestWhenRank(t *testing.T) {
+	defer withFlags(t)()
+
+	label := func(id int64, name string) map[int64]*maintner.GitHubLabel {
+		return map[int64]*maintner.GitHubLabel{id: {ID: id, Name: name}}
+	}
+	go113 := &maintner.GitHubMilestone{ID: 500, Number: 50, Title: "Go1.13"}
+	unplanned := &maintner.GitHubMilestone{ID: 1, Number: unplannedMilestone, Title: "Unplanned"}
+	for _, tt := range []struct {
+		m      *maintner.GitHubMilestone
+		labels map[int64]*maintner.GitHubLabel
+		when   string
+		want   string
+	}{
+		{go113, label(releaseBlockerID, "release-blocker"), "Go1.13", "1"},
+		{nil, label(releaseBlockerID, "release-blocker"), "release", "1"},
+		{nil, label(soonID, "Soon"), "soon", "2"},
+		{nil, label(earlyInCycleID, "early-in-cycle"), "early", "3"},
+		{nil, label(documentationID, "Documentation"), "doc", "7"},
+		{unplanned, nil, "unplanned", "9"},
+		{go113, nil, "", ""},
+	} {
+		i := newIssue(&maintner.GitHubIssue{Number: 1, Milestone: tt.m, Labels: tt.labels}, nil)
+		if i.when != tt.when {
+			t.Errorf("when in %v with labels %v = %q; want %q", tt.m, tt.labels, i.when, tt.when)
+		}
+		if got := columnValue(t, nil, nil, "when_rank", i); got != tt.want {
+			t.Errorf("when_rank for %q = %q; want %q", i.when, got, tt.want)
+		}
+	}
+}
+
+func TestCommentsLast30d(t *testing.T) {
+	gopher := &maintpb.GithubUser{Id: 10, Login: "gopher"}
+	comment := func(id int64, days int) *maintpb.GithubIssueCommentMutation {
+		return &maintpb.GithubIssueCommentMutation{Id: id, User: gopher, Created: ts(testNow.AddDate(0, 0, days)), Body: "comment"}
+	}
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{Number: 1, Created: ts(testNow.AddDate(0, -3, 0)), Comment: []*maintpb.GithubIssueCommentMutation{
+			comment(101, -60),
+			comment(102, -31),
+			comment(103, -29),
+			comment(104, -10),
+			comment(105, -1),
+		}},
+		&maintpb.GithubIssueMutation{Number: 2, Created: ts(testNow.AddDate(0, -3, 0)), Comment: []*maintpb.GithubIssueCommentMutation{
+			comment(201, -45),
+		}},
+		&maintpb.GithubIssueMutation{Number: 3, Created: ts(testNow.AddDate(0, -3, 0))},
+	)
+
+	for _, tt := range []struct {
+		flags []string
+		want  map[int32]string
+	}{
+		{nil, map[int32]string{1: "3", 2: "0", 3: "0"}},
+		{[]string{"comment-window=240h"}, map[int32]string{1: "2", 2: "0", 3: "0"}},
+		{[]string{"comment-window=1440h"}, map[int32]string{1: "5", 2: "1", 3: "0"}},
+	} {
+		restore := withFlags(t, tt.flags...)
+		for n, want := range tt.want {
+			got := columnValue(t, repo, nil, "comments_last_30d", newIssue(repo.Issue(n), nil))
+			if got != want {
+				t.Errorf("with flags %q, #%d: comments_last_30d = %q; want %q", tt.flags, n, got, want)
+			}
+		}
+		restore()
+	}
+}
+
+func TestBlocksRelease(t *testing.T) {
+	blocker := []*maintpb.GithubLabel{{Id: releaseBlockerID, Name: "release-blocker"}}
+	issue := func(number int32, milestone string, labels []*maintpb.GithubLabel) *maintpb.GithubIssueMutation {
+		m := &maintpb.GithubIssueMutation{
+			Number:   number,
+			Created:  ts(testNow.AddDate(0, -1, 0)),
+			AddLabel: labels,
+		}
+		switch milestone {
+		case "Go1.13":
+			m.MilestoneId, m.MilestoneNum, m.MilestoneTitle = 500, 50, milestone
+		case "Go1.14":
+			m.MilestoneId, m.MilestoneNum, m.MilestoneTitle = 501, 51, milestone
+		default:
+			m.NoMilestone = true
+		}
+		return m
+	}
+	// Go1.13, the earliest open release milestone, is the active cycle.
+	repo := newTestRepo(t,
+		issue(1, "Go1.13", blocker),
+		issue(2, "Go1.14", blocker),
+		issue(3, "Go1.13", nil),
+		issue(4, "", blocker),
+	)
+
+	for _, tt := range []struct {
+		flags []string
+		want  map[int32]string
+	}{
+		{nil, map[int32]string{1: "true", 2: "false", 3: "false", 4: "false"}},
+		{[]string{"cycle=Go1.14"}, map[int32]string{1: "false", 2: "true", 3: "false", 4: "false"}},
+	} {
+		restore := withFlags(t, tt.flags...)
+		c := takeCensus(repo)
+		for n, want := range tt.want {
+			got := columnValue(t, repo, c, "blocks_release", newIssue(repo.Issue(n), nil))
+			if got != want {
+				t.Errorf("with flags %q, #%d: blocks_release = %q; want %q", tt.flags, n, got, want)
+			}
+		}
+		restore()
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	defer withFlags(t)()
+
+	var (
+		needsFix      = &maintpb.GithubLabel{Id: needsFixID, Name: "NeedsFix"}
+		documentation = &maintpb.GithubLabel{Id: documentationID, Name: "Documentation"}
+		soon          = &maintpb.GithubLabel{Id: soonID, Name: "Soon"}
+	)
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{Number: 1, Created: ts(now), AddLabel: []*maintpb.GithubLabel{needsFix, documentation, soon}},
+		&maintpb.GithubIssueMutation{Number: 2, Created: ts(now), AddLabel: []*maintpb.GithubLabel{needsFix, soon}},
+		&maintpb.GithubIssueMutation{Number: 2, RemoveLabel: []int64{soonID}},
+		&maintpb.GithubIssueMutation{Number: 3, Created: ts(now)},
+	)
+
+	for n, want := range map[int32]string{1: "3", 2: "1", 3: "0"} {
+		got := columnValue(t, repo, nil, "label_count", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: label_count = %q; want %q", n, got, want)
+		}
+	}
+}
+
+func TestEffort(t *testing.T) {
+	defer withFlags(t, "size-labels=Size-S=1,Size-M=2.5", "size-labels=Size-L=5")()
+
+	sized := func(names ...string) *issue {
+		gi := &maintner.GitHubIssue{Number: 1, Labels: map[int64]*maintner.GitHubLabel{}}
+		for i, name := range names {
+			gi.Labels[int64(i+1)] = &maintner.GitHubLabel{ID: int64(i + 1), Name: name}
+		}
+		return newIssue(gi, nil)
+	}
+	for _, tt := range []struct {
+		labels []string
+		want   string
+	}{
+		{[]string{"Size-S"}, "1"},
+		{[]string{"NeedsFix", "Size-M"}, "2.5"},
+		{[]string{"Size-L", "Size-S"}, "5"}, // The largest size wins.
+		{[]string{"NeedsFix"}, ""},
+		{nil, ""},
+	} {
+		if got := columnValue(t, nil, nil, "effort", sized(tt.labels...)); got != tt.want {
+			t.Errorf("effort with labels %q = %q; want %q", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestSizeLabelsErrors(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  string
+	}{
+		{"Size-S", `missing '=' in "Size-S"`},
+		{"Size-S=small", `invalid value in "Size-S=small"`},
+	} {
+		if err := (labelValues{}).Set(tt.value); err == nil || err.Error() != tt.want {
+			t.Errorf("Set(%q): got error %v; want %s", tt.value, err, tt.want)
+		}
+	}
+}
+
+func TestAssigneeChanges(t *testing.T) {
+	defer withFlags(t)()
+
+	alice := &maintpb.GithubUser{Id: 10, Login: "alice"}
+	bob := &maintpb.GithubUser{Id: 11, Login: "bob"}
+	event := func(id int64, typ string, u *maintpb.GithubUser, days int) *maintpb.GithubIssueEvent {
+		return &maintpb.GithubIssueEvent{Id: id, EventType: typ, AssigneeId: u.Id, Created: ts(now.AddDate(0, 0, days))}
+	}
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{Number: 1, Created: ts(now.AddDate(0, -1, 0)), Assignees: []*maintpb.GithubUser{bob}, Event: []*maintpb.GithubIssueEvent{
+			event(101, "assigned", alice, -20),
+			event(102, "labeled", alice, -18),
+			event(103, "unassigned", alice, -15),
+			event(104, "assigned", bob, -15),
+			event(105, "unassigned", bob, -10),
+			event(106, "assigned", bob, -5),
+		}},
+		&maintpb.GithubIssueMutation{Number: 2, Created: ts(now.AddDate(0, -1, 0)), Event: []*maintpb.GithubIssueEvent{
+			event(201, "labeled", alice, -20),
+		}},
+		// Without any events, the changes are unknown.
+		&maintpb.GithubIssueMutation{Number: 3, Created: ts(now.AddDate(0, -1, 0)), Assignees: []*maintpb.GithubUser{alice}},
+	)
+
+	for n, want := range map[int32]string{1: "5", 2: "0", 3: ""} {
+		got := columnValue(t, repo, nil, "assignee_changes", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: assignee_changes = %q; want %q", n, got, want)
+		}
+	}
+}
+
+func TestSLABreach(t *testing.T) {
+	defer withFlags(t, "triage-sla=168h")()
+
+	week := 7 * 24 * time.Hour
+	needsFix := map[int64]*maintner.GitHubLabel{needsFixID: {ID: needsFixID, Name: "NeedsFix"}}
+	for _, tt := range []struct {
+		age    time.Duration
+		labels map[int64]*maintner.GitHubLabel
+		closed bool
+		want   string
+	}{
+		{week - time.Second, nil, false, "false"},
+		{week, nil, false, "false"}, // Exactly at the SLA is not yet a breach.
+		{week + time.Second, nil, false, "true"},
+		{2 * week, needsFix, false, "false"},
+		{2 * week, nil, true, ""},
+	} {
+		gi := &maintner.GitHubIssue{Number: 1, Created: now.Add(-tt.age), Labels: tt.labels, Closed: tt.closed}
+		if got := columnValue(t, nil, nil, "sla_breach", newIssue(gi, nil)); got != tt.want {
+			t.Errorf("sla_breach at age %v (labels %v, closed %v) = %q; want %q", tt.age, tt.labels, tt.closed, got, tt.want)
+		}
+	}
+}
+
+func TestMilestoneIsOpen(t *testing.T) {
+	defer withFlags(t)()
+
+	for _, tt := range []struct {
+		m    *maintner.GitHubMilestone
+		want string
+	}{
+		{&maintner.GitHubMilestone{ID: 500, Number: 50, Title: "Go1.13"}, "true"},
+		{&maintner.GitHubMilestone{ID: 490, Number: 40, Title: "Go1.12", Closed: true}, "false"},
+		{nil, ""},
+	} {
+		gi := &maintner.GitHubIssue{Number: 1, Milestone: tt.m}
+		if got := columnValue(t, nil, nil, "milestone_is_open", newIssue(gi, nil)); got != tt.want {
+			t.Errorf("milestone_is_open in %v = %q; want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestTitleNeedsTriage(t *testing.T) {
+	defer withFlags(t, `untriaged-title-regexp=^(\[triage\]|proposal: \?)`)()
+
+	for _, tt := range []struct {
+		title string
+		want  string
+	}{
+		{"[triage] cmd/go: build fails", "true"},
+		{"proposal: ? add a flag", "true"},
+		{"cmd/go: [triage] build fails", "false"},
+		{"cmd/go: build fails", "false"},
+	} {
+		gi := &maintner.GitHubIssue{Number: 1, Title: tt.title}
+		if got := columnValue(t, nil, nil, "title_needs_triage", newIssue(gi, nil)); got != tt.want {
+			t.Errorf("title_needs_triage for %q = %q; want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestTitleNeedsTriageInvalid(t *testing.T) {
+	defer withFlags(t, "untriaged-title-regexp=[triage")()
+
+	_, _, err := availableColumns(nil, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "-untriaged-title-regexp: ") {
+		t.Errorf("availableColumns: got error %v; want an -untriaged-title-regexp error", err)
+	}
+}
+
+func TestHasMilestone(t *testing.T) {
+	defer withFlags(t)()
+
+	repo := newTestRepo(t,
+		inMilestone(1, false),
+		&maintpb.GithubIssueMutation{Number: 2, Created: ts(now), NoMilestone: true},
+		&maintpb.GithubIssueMutation{Number: 3, Created: ts(now)},
+	)
+
+	for n, want := range map[int32]string{1: "true", 2: "false", 3: "false"} {
+		got := columnValue(t, repo, nil, "has_milestone", newIssue(repo.Issue(n), nil))
+		if got != want {
+			t.Errorf("#%d: has_milestone = %q; want %q", n, got, want)
+		}
+	}
+}
+
+func TestCloseQuarter(t *testing.T) {
+	defer withFlags(t)()
+
+	for _, tt := range []struct {
+		closedAt string // RFC 3339, or "" for an open issue
+		want     string
+	}{
+		{"2024-01-01T00:00:00Z", "2024-Q1"},
+		{"2024-03-31T23:59:59Z", "2024-Q1"},
+		{"2024-04-01T00:00:00Z", "2024-Q2"},
+		{"2019-08-15T10:00:00Z", "2019-Q3"},
+		{"2019-12-31T20:00:00-05:00", "2020-Q1"}, // Quarters are in UTC.
+		{"", ""},
+	} {
+		gi := &maintner.GitHubIssue{Number: 1}
+		if tt.closedAt != "" {
+			at, err := time.Parse(time.RFC3339, tt.closedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gi.Closed, gi.ClosedAt = true, at
+		}
+		if got := columnValue(t, nil, nil, "close_quarter", newIssue(gi, nil)); got != tt.want {
+			t.Errorf("close_quarter for close at %q = %q; want %q", tt.closedAt, got, tt.want)
+		}
+	}
+}
+
+func TestDiscussionHeat(t *testing.T) {
+	gopher := &maintpb.GithubUser{Id: 10, Login: "gopher"}
+	comment := func(id int64, days int) *maintpb.GithubIssueCommentMutation {
+		return &maintpb.GithubIssueCommentMutation{Id: id, User: gopher, Created: ts(testNow.AddDate(0, 0, days)), Body: "comment"}
+	}
+	repo := newTestRepo(t,
+		&maintpb.GithubIssueMutation{Number: 1, Created: ts(testNow.AddDate(0, -3, 0)), Comment: []*maintpb.GithubIssueCommentMutation{
+			comment(101, -45),
+			comment(102, -20),
+			comment(103, -10),
+			comment(104, -1),
+		}},
+		&maintpb.GithubIssueMutation{Number: 2, Created: ts(testNow.AddDate(0, -3, 0))},
+	)
+
+	// Maintner does not mirror reactions, so only the comments count.
+	for _, tt := range []struct {
+		flags []string
+		want  map[int32]string
+	}{
+		{nil, map[int32]string{1: "3", 2: "0"}},
+		{[]string{"heat-comment-weight=0.5"}, map[int32]string{1: "1.5", 2: "0"}},
+		{[]string{"heat-comment-weight=2", "comment-window=1440h"}, map[int32]string{1: "8", 2: "0"}},
+	} {
+		restore := withFlags(t, tt.flags...)
+		for n, want := range tt.want {
+			got := columnValue(t, repo, nil, "discussion_heat", newIssue(repo.Issue(n), nil))
+			if got != want {
+				t.Errorf("with flags %q, #%d: discussion_heat = %q; want %q", tt.flags, n, got, want)
+			}
+		}
+		restore()
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An export is the contents of a previous CSV export, indexed by issue number.
+type export struct {
+	names   []string
+	header  bool // whether the file began with a header row
+	records map[string][]string
+}
+
+// readExport reads a CSV export previously written by goissues.
+//
+// Fields equal to -null-value are read as empty.
+//
+// If the file begins with a header row (as written with -header), the header
+// names its columns. Otherwise, the file is assumed to have the given
+// columns, which must then match the number of fields in each record.
+func readExport(file string, names []string) (*export, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	e := &export{names: names, records: map[string][]string{}}
+	first, err := r.Read()
+	if err == io.EOF {
+		return e, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if isHeader(first) {
+		e.names, e.header = first, true
+		first = nil
+	} else if len(first) != len(names) {
+		return nil, fmt.Errorf("%s: records have %d fields, but %d columns are being exported (write the export with -header to compare different columns)", file, len(first), len(names))
+	}
+
+	numberCol := -1
+	for i, name := range e.names {
+		if name == "number" {
+			numberCol = i
+			break
+		}
+	}
+	if numberCol < 0 {
+		return nil, fmt.Errorf("%s: missing \"number\" column", file)
+	}
+
+	rec := first
+	for {
+		if rec != nil {
+			// Empty fields may have been written as -null-value, but are
+			// compared with the current values before it is applied.
+			if *nullValue != "" {
+				for i, v := range rec {
+					if v == *nullValue {
+						rec[i] = ""
+					}
+				}
+			}
+			e.records[rec[numberCol]] = rec
+		}
+		rec, err = r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	return e, nil
+}
+
+// isHeader reports whether rec, the first record of an export, is a header
+// row. Every record of an export with a "number" column has an all-digit
+// field, whereas the header instead has a field named "number".
+func isHeader(rec []string) bool {
+	hasNumber := false
+	for _, v := range rec {
+		if v == "number" {
+			hasNumber = true
+		}
+		if v != "" && strings.Trim(v, "0123456789") == "" {
+			return false
+		}
+	}
+	return hasNumber
+}
+
+// A diffWriter is a recordWriter that writes only the records that differ
+// from those in a previous export.
+//
+// In CSV format, it writes the complete current record for each issue that is
+// new or changed. In JSON format, it writes one sparse object per new, changed,
+// or removed issue, containing the issue number and the old and new values of
+// only the fields that differ.
+type diffWriter struct {
+	format string
+	names  []string
+	prev   *export
+	w      recordWriter  // for CSV
+	enc    *json.Encoder // for JSON
+
+	// prevCol maps the index of each current column to the index of the
+	// column with the same name in prev, or -1 if prev lacks that column.
+	// Columns missing from prev are not compared.
+	prevCol   []int
+	numberCol int
+	seen      map[string]bool
+}
+
+func newDiffWriter(w io.Writer, format string, names []string, header bool, prev *export) (*diffWriter, error) {
+	d := &diffWriter{
+		format:    format,
+		names:     names,
+		prev:      prev,
+		prevCol:   make([]int, len(names)),
+		numberCol: -1,
+		seen:      map[string]bool{},
+	}
+	for i, name := range names {
+		if name == "number" {
+			d.numberCol = i
+		}
+		d.prevCol[i] = -1
+		for j, prevName := range prev.names {
+			if prevName == name {
+				d.prevCol[i] = j
+				break
+			}
+		}
+	}
+	if d.numberCol < 0 {
+		return nil, fmt.Errorf("-diff-against requires the \"number\" column")
+	}
+
+	switch format {
+	case "json":
+		d.enc = json.NewEncoder(w)
+	default:
+		rw, err := newRecordWriter(w, format, names, header, nil)
+		if err != nil {
+			return nil, err
+		}
+		d.w = rw
+	}
+	return d, nil
+}
+
+func (d *diffWriter) Write(values []string) error {
+	number := values[d.numberCol]
+	d.seen[number] = true
+	old := d.prev.records[number]
+
+	changed := d.changes(old, values)
+	if len(changed) == 0 {
+		return nil
+	}
+	if d.enc == nil {
+		return d.w.Write(values)
+	}
+	return d.writeSparse(number, old, values, changed)
+}
+
+func (d *diffWriter) Flush() error {
+	if d.enc == nil {
+		return d.w.Flush()
+	}
+
+	// Report the issues that were removed since the previous export.
+	var removed []string
+	for number := range d.prev.records {
+		if !d.seen[number] {
+			removed = append(removed, number)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		ni, _ := strconv.Atoi(removed[i])
+		nj, _ := strconv.Atoi(removed[j])
+		return ni < nj
+	})
+	for _, number := range removed {
+		old := d.prev.records[number]
+		values := make([]string, len(d.names))
+		if err := d.writeSparse(number, old, values, d.changes(old, values)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changes returns the indices of the columns whose values differ between
+// the previous record old (which may be nil) and the current values.
+func (d *diffWriter) changes(old, values []string) (changed []int) {
+	for i, v := range values {
+		if i == d.numberCol {
+			continue
+		}
+		j := d.prevCol[i]
+		if j < 0 {
+			continue
+		}
+		prev := ""
+		if old != nil && j < len(old) {
+			prev = old[j]
+		}
+		if prev != v {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+func (d *diffWriter) writeSparse(number string, old, values []string, changed []int) error {
+	obj := object{{"number", number}}
+	for _, i := range changed {
+		prev := ""
+		if old != nil && d.prevCol[i] < len(old) {
+			prev = old[d.prevCol[i]]
+		}
+		obj = append(obj, field{d.names[i], object{{"old", prev}, {"new", values[i]}}})
+	}
+	return d.enc.Encode(obj)
+}
+
+// An assigneeDiffWriter is a recordWriter that writes, for each issue present
+// in both the current and the previous export whose set of assignees differs,
+// the issue number and its old and new assignees.
+type assigneeDiffWriter struct {
+	w       recordWriter
+	prev    *export
+	prevWho int // index of "who" in prev.names
+
+	numberCol, whoCol int
+}
+
+func newAssigneeDiffWriter(w io.Writer, format string, names []string, header bool, prev *export) (*assigneeDiffWriter, error) {
+	d := &assigneeDiffWriter{prev: prev, prevWho: -1, numberCol: -1, whoCol: -1}
+	for i, name := range names {
+		switch name {
+		case "number":
+			d.numberCol = i
+		case "who":
+			d.whoCol = i
+		}
+	}
+	for j, name := range prev.names {
+		if name == "who" {
+			d.prevWho = j
+		}
+	}
+	if d.numberCol < 0 || d.whoCol < 0 {
+		return nil, fmt.Errorf("-diff-assignees requires the \"number\" and \"who\" columns")
+	}
+	if d.prevWho < 0 {
+		return nil, fmt.Errorf("-diff-assignees: previous export lacks the \"who\" column")
+	}
+
+	rw, err := newRecordWriter(w, format, []string{"number", "old_who", "new_who"}, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	d.w = rw
+	return d, nil
+}
+
+func (d *assigneeDiffWriter) Write(values []string) error {
+	number := values[d.numberCol]
+	old := d.prev.records[number]
+	if old == nil || d.prevWho >= len(old) {
+		return nil
+	}
+	oldWho, newWho := old[d.prevWho], values[d.whoCol]
+	if sameLogins(oldWho, newWho) {
+		return nil
+	}
+	return d.w.Write([]string{number, oldWho, newWho})
+}
+
+func (d *assigneeDiffWriter) Flush() error { return d.w.Flush() }
+
+// sameLogins reports whether the comma-separated lists of logins a and b
+// contain the same logins, in any order.
+func sameLogins(a, b string) bool {
+	split := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		l := strings.Split(s, ",")
+		sort.Strings(l)
+		return l
+	}
+	return strings.Join(split(a), ",") == strings.Join(split(b), ",")
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeExport writes content to a file named prev.csv in a new temporary
+// directory, returning the file's path and a function that removes the
+// directory.
+func writeExport(t *testing.T, content string) (file string, cleanup func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "goissues-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file = filepath.Join(dir, "prev.csv")
+	if err := ioutil.WriteFile(file, []byte(content), 0666); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return file, func() { os.RemoveAll(dir) }
+}
+
+func TestDiffWriterSparseJSON(t *testing.T) {
+	defer withFlags(t)()
+
+	file, cleanup := writeExport(t, `number,updated,state,title
+1,2019-04-01,open,unchanged
+2,2019-04-01,open,old title
+3,2019-04-01,open,removed
+`)
+	defer cleanup()
+
+	names := []string{"number", "updated", "state", "title"}
+	prev, err := readExport(file, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := newDiffWriter(&buf, "json", names, false, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range [][]string{
+		{"1", "2019-04-01", "open", "unchanged"},
+		{"2", "2019-04-01", "closed", "new title"},
+		{"4", "2019-04-02", "open", "added"},
+	} {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"number":"2","state":{"old":"open","new":"closed"},"title":{"old":"old title","new":"new title"}}
+{"number":"4","updated":{"old":"","new":"2019-04-02"},"state":{"old":"","new":"open"},"title":{"old":"","new":"added"}}
+{"number":"3","updated":{"old":"2019-04-01","new":""},"state":{"old":"open","new":""},"title":{"old":"removed","new":""}}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDiffNullValue(t *testing.T) {
+	defer withFlags(t, `null-value=\N`)()
+
+	// Fields written as -null-value compare equal to empty current values.
+	file, cleanup := writeExport(t, `number,updated,first_assigned_at
+1,2019-04-01,\N
+2,2019-04-01,\N
+`)
+	defer cleanup()
+
+	names := []string{"number", "updated", "first_assigned_at"}
+	prev, err := readExport(file, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := newDiffWriter(&buf, "csv", names, false, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]string{"1", "2019-04-01", ""})
+	w.Write([]string{"2", "2019-04-01", "2019-04-01"})
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := buf.String(), "2,2019-04-01,2019-04-01\n"; got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDiffAssignees(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	names := []string{"number", "state", "who"}
+	prev := filepath.Join(dir, "monday.csv")
+	restore := withFlags(t, "header", "o="+prev)
+	writeOutput(t, names, *writeHeader, [][]string{
+		{"1", "open", "alice"},
+		{"2", "open", "alice,bob"},
+		{"3", "open", ""},
+		{"4", "open", "carol"},
+	})
+	restore()
+
+	// Only the assignees matter: #1 changed state, #2 was reordered, #5 is new.
+	cur := filepath.Join(dir, "tuesday.csv")
+	defer withFlags(t, "header", "o="+cur, "diff-against="+prev, "diff-assignees")()
+	writeOutput(t, names, *writeHeader, [][]string{
+		{"1", "pending", "alice"},
+		{"2", "open", "bob,alice"},
+		{"3", "open", "dave"},
+		{"4", "open", ""},
+		{"5", "open", "erin"},
+	})
+
+	want := `number,old_who,new_who
+3,,dave
+4,carol,
+`
+	if got := readFile(t, cur); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
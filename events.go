@@ -0,0 +1,172 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"time"
+
+	"golang.org/x/build/maintner"
+)
+
+// labeledSince reports whether any label was added to gi at or after t.
+//
+// Maintner does not distinguish an issue with no events from one whose events
+// have not been synced, so if gi has no events at all, hasEvents is false.
+func labeledSince(gi *maintner.GitHubIssue, t time.Time) (labeled, hasEvents bool) {
+	gi.ForeachEvent(func(e *maintner.GitHubIssueEvent) error {
+		hasEvents = true
+		if e.Type == "labeled" && !e.Created.Before(t) {
+			labeled = true
+		}
+		return nil
+	})
+	return labeled, hasEvents
+}
+
+// lastActivityBy returns the time of the most recent event or comment on gi
+// by any of the given logins, or the zero time if there is none.
+func lastActivityBy(gi *maintner.GitHubIssue, logins []string) time.Time {
+	by := func(u *maintner.GitHubUser) bool {
+		if u == nil {
+			return false
+		}
+		for _, login := range logins {
+			if u.Login == login {
+				return true
+			}
+		}
+		return false
+	}
+
+	var last time.Time
+	gi.ForeachEvent(func(e *maintner.GitHubIssueEvent) error {
+		if by(e.Actor) && e.Created.After(last) {
+			last = e.Created
+		}
+		return nil
+	})
+	gi.ForeachComment(func(c *maintner.GitHubComment) error {
+		if by(c.User) && c.Created.After(last) {
+			last = c.Created
+		}
+		return nil
+	})
+	return last
+}
+
+// countEvents returns the number of events on gi of any of the given types.
+// As with labeledSince, hasEvents is false if gi has no events at all.
+func countEvents(gi *maintner.GitHubIssue, types ...string) (n int, hasEvents bool) {
+	gi.ForeachEvent(func(e *maintner.GitHubIssueEvent) error {
+		hasEvents = true
+		for _, t := range types {
+			if e.Type == t {
+				n++
+				break
+			}
+		}
+		return nil
+	})
+	return n, hasEvents
+}
+
+// lastEvent returns the time of the latest event on gi of the given type
+// for which match (if non-nil) returns true, or the zero time if there is none.
+func lastEvent(gi *maintner.GitHubIssue, typ string, match func(*maintner.GitHubIssueEvent) bool) time.Time {
+	var last time.Time
+	gi.ForeachEvent(func(e *maintner.GitHubIssueEvent) error {
+		if e.Type == typ && (match == nil || match(e)) && e.Created.After(last) {
+			last = e.Created
+		}
+		return nil
+	})
+	return last
+}
+
+// firstEvent returns the time of the earliest event on gi of the given type,
+// or the zero time if there is none.
+func firstEvent(gi *maintner.GitHubIssue, typ string) time.Time {
+	var first time.Time
+	gi.ForeachEvent(func(e *maintner.GitHubIssueEvent) error {
+		if e.Type == typ && (first.IsZero() || e.Created.Before(first)) {
+			first = e.Created
+		}
+		return nil
+	})
+	return first
+}
+
+// stateSince returns a best-effort estimate of when i entered its current
+// state, or the zero time if that cannot be inferred.
+func stateSince(i *issue) time.Time {
+	// labeled returns the time at which any of the labels with the given IDs
+	// currently on i was most recently added.
+	labeled := func(ids ...int64) time.Time {
+		return lastEvent(i.GitHubIssue, "labeled", func(e *maintner.GitHubIssueEvent) bool {
+			for _, id := range ids {
+				if l := i.Labels[id]; l != nil && l.Name == e.Label {
+					return true
+				}
+			}
+			return false
+		})
+	}
+
+	switch i.state {
+	case "closed":
+		return i.ClosedAt
+	case "locked":
+		return lastEvent(i.GitHubIssue, "locked", nil)
+	case "waiting":
+		return labeled(waitingForInfoID, proposalHoldID)
+	case "deciding":
+		return labeled(needsDecisionID)
+	case "pending":
+		// The issue became pending when its first live CL was uploaded.
+		var first time.Time
+		for _, cl := range i.cls.live {
+			if first.IsZero() || cl.Created.Before(first) {
+				first = cl.Created
+			}
+		}
+		return first
+	case "open":
+		if t := lastEvent(i.GitHubIssue, "reopened", nil); !t.IsZero() {
+			return t
+		}
+		return i.Created
+	}
+	// A blocked issue became blocked when its last live CL was vetoed,
+	// but maintner does not make the time of each vote readily available.
+	return time.Time{}
+}
+
+// lastCommentExcept returns the time of the most recent comment on gi by an
+// author whose login is not in skip, or the zero time if there is none.
+func lastCommentExcept(gi *maintner.GitHubIssue, skip map[string]bool) time.Time {
+	var last time.Time
+	gi.ForeachComment(func(c *maintner.GitHubComment) error {
+		if c.User != nil && skip[c.User.Login] {
+			return nil
+		}
+		if c.Created.After(last) {
+			last = c.Created
+		}
+		return nil
+	})
+	return last
+}
+
+// commentsSince returns the number of comments on gi created at or after t.
+func commentsSince(gi *maintner.GitHubIssue, t time.Time) int {
+	n := 0
+	gi.ForeachComment(func(c *maintner.GitHubComment) error {
+		if !c.Created.Before(t) {
+			n++
+		}
+		return nil
+	})
+	return n
+}
@@ -0,0 +1,34 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Exit codes.
+const (
+	exitFailure  = 1 // any error not covered below
+	exitNotFound = 3 // the Gerrit project or GitHub repo is missing from the corpus
+)
+
+// fatalf reports an error and exits with the given code.
+//
+// With -format=json or -format=json-envelope, the error is written to stderr
+// as a JSON object of the form {"error": "...", "code": N}, so that automation
+// consuming the output need not parse log messages.
+func fatalf(code int, msg string, args ...interface{}) {
+	msg = fmt.Sprintf(msg, args...)
+	if *format == "json" || *format == "json-envelope" {
+		b, _ := json.Marshal(object{{"error", msg}, {"code", code}})
+		fmt.Fprintf(os.Stderr, "%s\n", b)
+	} else {
+		log.Print(msg)
+	}
+	os.Exit(code)
+}
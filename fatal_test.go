@@ -0,0 +1,64 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// fatalfFormatEnv, if set in the environment, causes TestFatalf to call fatalf
+// with -format set to its value, rather than running the test.
+const fatalfFormatEnv = "GOISSUES_TEST_FATALF_FORMAT"
+
+func TestFatalf(t *testing.T) {
+	if f, ok := os.LookupEnv(fatalfFormatEnv); ok {
+		*format = f
+		fatalf(exitNotFound, "%s not found", "github.com/golang/go")
+		return
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skipf("can't re-run test binary: %v", err)
+	}
+	for _, f := range []string{"csv", "json", "json-envelope"} {
+		cmd := exec.Command(exe, "-test.run=^TestFatalf$")
+		cmd.Env = append(os.Environ(), fatalfFormatEnv+"="+f)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+
+		ee, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatalf("-format=%s: %v; want exit status %d\n%s", f, err, exitNotFound, stderr.Bytes())
+		}
+		if code := ee.ProcessState.ExitCode(); code != exitNotFound {
+			t.Errorf("-format=%s: exit status %d; want %d", f, code, exitNotFound)
+		}
+
+		if f == "csv" {
+			if !strings.HasSuffix(stderr.String(), "github.com/golang/go not found\n") {
+				t.Errorf("-format=%s: stderr = %q; want a log message", f, stderr.String())
+			}
+			continue
+		}
+		var got struct {
+			Error string
+			Code  int
+		}
+		if err := json.Unmarshal(stderr.Bytes(), &got); err != nil {
+			t.Errorf("-format=%s: %v\nstderr:\n%s", f, err, stderr.Bytes())
+			continue
+		}
+		if got.Error != "github.com/golang/go not found" || got.Code != exitNotFound {
+			t.Errorf("-format=%s: stderr = %q; want error %q and code %d", f, stderr.String(), "github.com/golang/go not found", exitNotFound)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/build/maintner"
+)
+
+// A listFlag is a flag.Value holding a list of strings.
+// Each use of the flag appends its comma-separated elements to the list.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(s string) error {
+	for _, elem := range strings.Split(s, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			*l = append(*l, elem)
+		}
+	}
+	return nil
+}
+
+// A boolFilter is a flag.Value for a filter that may require a condition to
+// be either true or false, or leave it unconstrained if the flag is not set.
+type boolFilter struct {
+	set, want bool
+}
+
+func (b *boolFilter) String() string {
+	if !b.set {
+		return ""
+	}
+	return strconv.FormatBool(b.want)
+}
+
+func (b *boolFilter) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	b.set, b.want = true, v
+	return nil
+}
+
+func (b *boolFilter) IsBoolFlag() bool { return true }
+
+// allows reports whether the filter admits an issue for which the condition
+// has the value v.
+func (b *boolFilter) allows(v bool) bool { return !b.set || b.want == v }
+
+var (
+	hasCL             boolFilter
+	staleCL           boolFilter
+	milestones        listFlag
+	excludeMilestones listFlag
+	states            listFlag
+	whens             listFlag
+	assignees         listFlag
+	excludeAssignees  listFlag
+	labelFilter       listFlag
+)
+
+func init() {
+	flag.Var(&hasCL, "has-cl", "if set, include only issues that have (or, if false, lack) an open CL without a -2 vote")
+	flag.Var(&staleCL, "stale-cl", "with -cl-stale, include only issues whose open CLs without a -2 vote are (or, if false, are not) all stale; issues without such CLs are excluded")
+	flag.Var(&milestones, "milestone", "if set, include only issues in one of these comma-separated milestones (by title)")
+	flag.Var(&excludeMilestones, "exclude-milestone", "exclude issues in any of these comma-separated milestones (by title); may be repeated")
+	flag.Var(&states, "state", "if set, include only issues in one of these comma-separated states")
+	flag.Var(&assignees, "assignee", "if set, include only issues assigned to at least one of these comma-separated logins")
+	flag.Var(&excludeAssignees, "exclude-assignee", "exclude issues assigned to any of these comma-separated logins; may be repeated")
+	flag.Var(&labelFilter, "label", "if set, include only issues with at least one of these comma-separated labels (by name)")
+	flag.Var(&whens, "when", "if set, include only issues whose \"when\" matches one of these comma-separated patterns (as in path.Match)")
+}
+
+// include reports whether i passes the filters selected by the command-line
+// flags.
+func include(i *issue) bool {
+	if age := now.Sub(i.Created); (*minAge > 0 && age < *minAge) || (*maxAge > 0 && age > *maxAge) {
+		return false
+	}
+	if !hasCL.allows(i.hasLiveCL()) {
+		return false
+	}
+	if staleCL.set {
+		stale, ok := i.cls.stale(*clStale)
+		if !ok || !staleCL.allows(stale) {
+			return false
+		}
+	}
+	if len(milestones) > 0 || len(excludeMilestones) > 0 {
+		title := ""
+		if m := milestone(i.GitHubIssue); m != nil {
+			title = m.Title
+		}
+		if len(milestones) > 0 && !contains(milestones, title) {
+			return false
+		}
+		if contains(excludeMilestones, title) {
+			return false
+		}
+	}
+	if len(assignees) > 0 || len(excludeAssignees) > 0 {
+		assigned := false
+		for _, login := range i.who {
+			if contains(excludeAssignees, login) {
+				return false
+			}
+			if contains(assignees, login) {
+				assigned = true
+			}
+		}
+		if len(assignees) > 0 && !assigned {
+			return false
+		}
+	}
+	if len(labelFilter) > 0 {
+		labeled := false
+		for _, name := range labelFilter {
+			if i.HasLabel(name) {
+				labeled = true
+				break
+			}
+		}
+		if !labeled {
+			return false
+		}
+	}
+	if len(states) > 0 && !contains(states, i.state) {
+		return false
+	}
+	if *whenMilestoneMismatch && i.when == i.milestoneWhen {
+		return false
+	}
+	if len(whens) > 0 {
+		matched := false
+		for _, pattern := range whens {
+			if ok, _ := path.Match(pattern, i.when); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether list contains s.
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
+
+// loadNumbers reads a file of issue numbers, one per line.
+// Blank lines and lines beginning with '#' are ignored, and numbers after the
+// first occurrence of each are dropped with a warning.
+func loadNumbers(file string) ([]int32, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	numbers := []int32{}
+	seen := map[int32]bool{}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		num, err := strconv.ParseInt(line, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid issue number %q", file, n+1, line)
+		}
+		if seen[int32(num)] {
+			log.Printf("warning: %s:%d: duplicate issue number %d", file, n+1, num)
+			continue
+		}
+		seen[int32(num)] = true
+		numbers = append(numbers, int32(num))
+	}
+	return numbers, nil
+}
+
+// warnMissing logs a warning for each of numbers that is not an issue in repo.
+func warnMissing(repo *maintner.GitHubRepo, numbers []int32) {
+	for _, n := range numbers {
+		switch gi := repo.Issue(n); {
+		case gi == nil || gi.NotExist:
+			log.Printf("warning: issue %d not found in %s", n, repo.ID())
+		case gi.PullRequest:
+			log.Printf("warning: %s#%d is a pull request, not an issue", repo.ID(), n)
+		}
+	}
+}
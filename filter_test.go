@@ -0,0 +1,268 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/build/maintner"
+)
+
+// included returns the numbers of the issues in the given list that pass
+// the filters set by the current flags.
+func included(issues ...*issue) []int32 {
+	var numbers []int32
+	for _, i := range issues {
+		if include(i) {
+			numbers = append(numbers, i.Number)
+		}
+	}
+	return numbers
+}
+
+func TestHasCL(t *testing.T) {
+	for _, tt := range []struct {
+		flags []string
+		want  []int32
+	}{
+		{[]string{"has-cl=true"}, []int32{1}},
+		{[]string{"has-cl=false"}, []int32{2, 3}},
+		{nil, []int32{1, 2, 3}},
+	} {
+		restore := withFlags(t, tt.flags...)
+		repo, refs := newTestCLs(t, testIssues(1, 2, 3),
+			testCL{number: 101, fixes: []int32{1}},
+			testCL{number: 102, fixes: []int32{2}, votes: []testVote{{testNow.AddDate(0, 0, -1), "Code-Review=-2"}}},
+		)
+		var issues []*issue
+		for _, n := range []int32{1, 2, 3} {
+			issues = append(issues, newIssue(repo.Issue(n), refs[n]))
+		}
+		if got := included(issues...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("with flags %q: included %v; want %v", tt.flags, got, tt.want)
+		}
+		restore()
+	}
+}
+
+func TestWhenMilestoneMismatch(t *testing.T) {
+	defer withFlags(t, "when-milestone-mismatch")()
+
+	unplanned := &maintner.GitHubMilestone{ID: 1, Number: unplannedMilestone, Title: "Unplanned"}
+	withLabels := func(number int32, m *maintner.GitHubMilestone, labels ...*maintner.GitHubLabel) *issue {
+		gi := &maintner.GitHubIssue{Number: number, Milestone: m, Labels: map[int64]*maintner.GitHubLabel{}}
+		for _, l := range labels {
+			gi.Labels[l.ID] = l
+		}
+		return newIssue(gi, nil)
+	}
+	soon := &maintner.GitHubLabel{ID: soonID, Name: "Soon"}
+	issues := []*issue{
+		withLabels(1, unplanned, soon),            // "soon" overrides "unplanned".
+		withLabels(2, unplanned),                  // "unplanned" either way.
+		withLabels(3, unplanned, helpWantedLabel), // "help" either way.
+		withLabels(4, nil, documentLabel),         // "doc" overrides no milestone.
+		withLabels(5, nil),
+	}
+
+	if got, want := included(issues...), []int32{1, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("included %v; want %v", got, want)
+	}
+	for _, tt := range []struct {
+		i                   *issue
+		when, milestoneWhen string
+	}{
+		{issues[0], "soon", "unplanned"},
+		{issues[3], "doc", ""},
+	} {
+		if got := columnValue(t, nil, nil, "when", tt.i); got != tt.when {
+			t.Errorf("#%d: when = %q; want %q", tt.i.Number, got, tt.when)
+		}
+		if got := columnValue(t, nil, nil, "milestone_when", tt.i); got != tt.milestoneWhen {
+			t.Errorf("#%d: milestone_when = %q; want %q", tt.i.Number, got, tt.milestoneWhen)
+		}
+	}
+}
+
+func TestNumbersFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	file := filepath.Join(dir, "numbers.txt")
+	if err := ioutil.WriteFile(file, []byte("# To export.\n3\n\n99\n1\n3\n4\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	savedOutput, savedFlags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(savedOutput)
+		log.SetFlags(savedFlags)
+	}()
+
+	numbers, err := loadNumbers(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int32{3, 99, 1, 4}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("loadNumbers = %v; want %v", numbers, want)
+	}
+
+	issues := testIssues(1, 2, 3, 4)
+	issues[3].PullRequest = true
+	repo := newTestRepo(t, issues...)
+	warnMissing(repo, numbers)
+
+	want := "warning: " + file + ":6: duplicate issue number 3\n" +
+		"warning: issue 99 not found in golang/go\n" +
+		"warning: golang/go#4 is a pull request, not an issue\n"
+	if got := buf.String(); got != want {
+		t.Errorf("logged:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, tt := range []struct {
+		flags []string
+		want  []int32
+	}{
+		{nil, []int32{1, 3}},
+		{[]string{"preserve-order"}, []int32{3, 1}},
+	} {
+		restore := withFlags(t, tt.flags...)
+		var got []int32
+		err := selectIssues(repo, nil, numbers)(func(i *issue) error {
+			got = append(got, i.Number)
+			return nil
+		})
+		restore()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("with flags %q: visited %v; want %v", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestLoadNumbersError(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	file := filepath.Join(dir, "numbers.txt")
+	if err := ioutil.WriteFile(file, []byte("1\n#2\nthree\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	want := file + `:3: invalid issue number "three"`
+	if _, err := loadNumbers(file); err == nil || err.Error() != want {
+		t.Errorf("loadNumbers: got error %v; want %s", err, want)
+	}
+}
+
+func TestExcludeMilestone(t *testing.T) {
+	inMilestone := func(number int32, title string) *issue {
+		gi := &maintner.GitHubIssue{Number: number}
+		if title != "" {
+			gi.Milestone = &maintner.GitHubMilestone{ID: int64(len(title)), Title: title}
+		}
+		return newIssue(gi, nil)
+	}
+	issues := []*issue{
+		inMilestone(1, "Unplanned"),
+		inMilestone(2, "Go1.13"),
+		inMilestone(3, "Go1.14"),
+		inMilestone(4, ""),
+		inMilestone(5, "Backlog"),
+	}
+
+	for _, tt := range []struct {
+		flags []string
+		want  []int32
+	}{
+		{[]string{"exclude-milestone=Unplanned"}, []int32{2, 3, 4, 5}},
+		{[]string{"exclude-milestone=Unplanned", "exclude-milestone=Backlog"}, []int32{2, 3, 4}},
+		{[]string{"exclude-milestone=Unplanned,Backlog"}, []int32{2, 3, 4}},
+		{[]string{"milestone=Go1.13,Unplanned", "exclude-milestone=Unplanned"}, []int32{2}},
+	} {
+		restore := withFlags(t, tt.flags...)
+		if got := included(issues...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("with flags %q: included %v; want %v", tt.flags, got, tt.want)
+		}
+		restore()
+	}
+}
+
+func TestAgeRange(t *testing.T) {
+	created := func(number int32, days int) *issue {
+		return newIssue(&maintner.GitHubIssue{Number: number, Created: testNow.AddDate(0, 0, -days)}, nil)
+	}
+	issues := []*issue{
+		created(1, 10),
+		created(2, 30),
+		created(3, 60),
+		created(4, 90),
+		created(5, 120),
+	}
+	closed := created(6, 60)
+	closed.Closed = true
+	closed.state = "closed"
+	issues = append(issues, closed)
+
+	for _, tt := range []struct {
+		flags []string
+		want  []int32
+	}{
+		{[]string{"min-age=720h", "max-age=2160h"}, []int32{2, 3, 4, 6}},
+		{[]string{"min-age=720h", "max-age=2160h", "state=open"}, []int32{2, 3, 4}},
+		{[]string{"min-age=1000h"}, []int32{3, 4, 5, 6}},
+		{[]string{"max-age=1000h"}, []int32{1, 2}},
+	} {
+		restore := withFlags(t, tt.flags...)
+		if got := included(issues...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("with flags %q: included %v; want %v", tt.flags, got, tt.want)
+		}
+		restore()
+	}
+
+	// With -now before some issues were created and no age flags, their
+	// negative ages must not exclude them.
+	defer withFlags(t, "now=2019-04-01T00:00:00Z")()
+	var err error
+	if now, err = startTime(*nowFlag); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := included(issues...), []int32{1, 2, 3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with -now=%s: included %v; want %v", *nowFlag, got, want)
+	}
+}
+
+func TestExcludeAssignee(t *testing.T) {
+	issues := []*issue{
+		assigned(1, "x", "gopherbot"),
+		assigned(2, "x", "alice"),
+		assigned(3, "x", "alice", "gopherbot"),
+		assigned(4, "x", "bob"),
+		assigned(5, "x"),
+	}
+
+	for _, tt := range []struct {
+		flags []string
+		want  []int32
+	}{
+		{[]string{"exclude-assignee=gopherbot"}, []int32{2, 4, 5}},
+		{[]string{"exclude-assignee=gopherbot", "exclude-assignee=bob"}, []int32{2, 5}},
+		{[]string{"assignee=alice", "exclude-assignee=gopherbot"}, []int32{2}},
+	} {
+		restore := withFlags(t, tt.flags...)
+		if got := included(issues...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("with flags %q: included %v; want %v", tt.flags, got, tt.want)
+		}
+		restore()
+	}
+}
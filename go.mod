@@ -2,4 +2,8 @@ module github.com/bcmills/goissues
 
 go 1.13
 
-require golang.org/x/build v0.0.0-20190507185305-310754d993da
+require (
+	github.com/golang/protobuf v1.3.1
+	golang.org/x/build v0.0.0-20190507185305-310754d993da
+	golang.org/x/oauth2 v0.0.0-20190402181905-9f3314589c9a
+)
@@ -0,0 +1,214 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"golang.org/x/build/maintner"
+)
+
+// An issue is a GitHub issue together with the attributes that goissues
+// derives from its labels, milestone, and linked CLs.
+type issue struct {
+	*maintner.GitHubIssue
+
+	// state is one of "open", "closed", "locked", "waiting", "deciding",
+	// "pending" (with a CL out for review), or "blocked" (with only CLs that
+	// have been vetoed with a -2 Code-Review vote).
+	state string
+
+	// when is a coarse classification of when the issue should be addressed,
+	// such as "soon", "early", or the title of a release milestone.
+	// It is empty if no label or milestone suggests a time frame.
+	when string
+
+	// milestoneWhen is the classification implied by the issue's milestone
+	// alone. Labels may override it to produce when.
+	milestoneWhen string
+
+	// who lists the logins of the issue's assignees.
+	who []string
+
+	// cls records the CLs that refer to the issue.
+	// It is nil if there are none.
+	cls *issueCLs
+}
+
+// newIssue classifies gi, given the CLs that refer to it (which may be nil).
+func newIssue(gi *maintner.GitHubIssue, cls *issueCLs) *issue {
+	i := &issue{GitHubIssue: gi, cls: cls}
+
+	switch {
+	case gi.Closed:
+		i.state = "closed"
+	case gi.Locked:
+		i.state = "locked"
+	}
+
+	i.milestoneWhen = milestoneWhen(gi)
+	i.when = i.milestoneWhen
+
+	for _, l := range gi.Labels {
+		switch l.ID {
+		case waitingForInfoID, proposalHoldID:
+			switch i.state {
+			case "", "deciding":
+				i.state = "waiting"
+			}
+		case needsDecisionID:
+			switch i.state {
+			case "":
+				i.state = "deciding"
+			}
+
+		case soonID:
+			i.when = "soon"
+		case releaseBlockerID:
+			switch i.when {
+			case "", "early", "feature", "performance", "test", "doc":
+				if gi.Milestone != nil {
+					i.when = gi.Milestone.Title
+				} else {
+					i.when = "release"
+				}
+			}
+		case earlyInCycleID:
+			switch i.when {
+			case "", "feature", "performance", "test", "doc":
+				i.when = "early"
+			}
+		case featureRequestID:
+			switch i.when {
+			case "", "performance", "test", "doc":
+				i.when = "feature"
+			}
+		case performanceID, toolSpeedID:
+			switch i.when {
+			case "", "test", "doc":
+				i.when = "performance"
+			}
+		case testingID:
+			switch i.when {
+			case "", "doc":
+				i.when = "test"
+			}
+		case documentationID:
+			switch i.when {
+			case "":
+				i.when = "doc"
+			}
+		}
+	}
+
+	if i.state == "" {
+		switch {
+		case i.hasLiveCL():
+			i.state = "pending"
+		case cls != nil && len(cls.vetoed) > 0:
+			i.state = "blocked"
+		default:
+			i.state = "open"
+		}
+	}
+
+	for _, a := range gi.Assignees {
+		if a.Login == "" {
+			continue
+		}
+		i.who = append(i.who, a.Login)
+	}
+
+	return i
+}
+
+// whenRanks maps each "when" category to its priority, from 1 (most urgent)
+// upward. Release blockers rank highest, followed by the other label-derived
+// categories in the order in which newIssue lets them override one another,
+// and then the categories that come only from milestones. (newIssue itself
+// lets "soon" override a release blocker, but a blocker is the more urgent.)
+//
+// Release blockers have the title of their milestone (or "release") as their
+// category, so any category not listed here ranks as a release blocker.
+var whenRanks = map[string]int{
+	"release":     1,
+	"soon":        2,
+	"early":       3,
+	"feature":     4,
+	"performance": 5,
+	"test":        6,
+	"doc":         7,
+	"help":        8,
+	"unplanned":   9,
+	"unreleased":  10,
+	"proposal":    11,
+	"go2":         12,
+	"gccgo":       13,
+	"gollvm":      14,
+}
+
+// whenRank returns the priority of i's "when" category (see whenRanks),
+// or 0 if it has none.
+func (i *issue) whenRank() int {
+	if i.when == "" {
+		return 0
+	}
+	if r, ok := whenRanks[i.when]; ok {
+		return r
+	}
+	return whenRanks["release"]
+}
+
+// milestoneWhen returns the "when" classification implied by gi's milestone
+// alone. The only label it considers is "help wanted", which distinguishes
+// the two kinds of Unplanned issues.
+func milestoneWhen(gi *maintner.GitHubIssue) string {
+	if gi.Milestone != nil {
+		switch gi.Milestone.Number {
+		case unplannedMilestone:
+			if gi.HasLabelID(helpWantedID) {
+				return "help"
+			}
+			return "unplanned"
+		case unreleasedMilestone:
+			return "unreleased"
+		case proposalMilestone:
+			return "proposal"
+		case go2Milestone:
+			return "go2"
+		case gccgoMilestone:
+			return "gccgo"
+		case gollvmMilestone:
+			return "gollvm"
+		}
+	}
+	return ""
+}
+
+// hasLiveCL reports whether i has an open CL without a -2 vote.
+func (i *issue) hasLiveCL() bool {
+	return i.cls != nil && len(i.cls.live) > 0
+}
+
+// frozen reports whether gi has been locked automatically due to its age.
+func frozen(gi *maintner.GitHubIssue) bool {
+	return gi.Locked && gi.HasLabelID(frozenDueToAgeID)
+}
+
+// triaged reports whether i has been triaged: that is, whether it has a
+// milestone or one of the Needs* labels.
+func (i *issue) triaged() bool {
+	return milestone(i.GitHubIssue) != nil ||
+		i.HasLabelID(needsDecisionID) ||
+		i.HasLabelID(needsFixID) ||
+		i.HasLabelID(needsInvestigationID)
+}
+
+// row returns the values of cols for i.
+func (i *issue) row(cols []column) []string {
+	row := make([]string, len(cols))
+	for j, c := range cols {
+		row[j] = c.value(i)
+	}
+	return row
+}
@@ -8,11 +8,11 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
-	"log"
+	"flag"
+	"fmt"
 	"os"
-	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/build/maintner"
 	"golang.org/x/build/maintner/godata"
@@ -55,176 +55,221 @@ const (
 	gollvmMilestone     = 100
 )
 
+var (
+	ageUnits              = flag.String("age-units", "days", "unit for the age column: days, weeks, or months")
+	appendOutput          = flag.Bool("append", false, "append CSV records to the existing -o file (with -header, writing the header only if the file is empty)")
+	assigneeActivity      = flag.Bool("assignee-activity", false, "report the date of the latest event or comment on each issue by any of its assignees")
+	assigneeStale         = flag.Duration("assignee-stale", 0, "if nonzero, report whether each assigned open issue has gone at least this long without an update")
+	bots                  = flag.String("bots", "gopherbot,gobot", "comma-separated logins of bots, whose comments are ignored by days_since_human_comment")
+	bqTable               = flag.String("bq-table", "", "with -format=bigquery, the BigQuery table to insert into, as project.dataset.table; created if missing")
+	checkIDsMode          checkMode
+	chunkSize             = flag.Int("chunk-size", 0, "if positive, write at most this many issues per file, as numbered files in the -o directory")
+	clBranch              = flag.String("cl-branch", "", "if set, consider only CLs targeting this branch (for example, master) for the state and CL columns")
+	clStale               = flag.Duration("cl-stale", 0, "if nonzero, add a cl_stale column reporting whether each issue's open CLs have all gone at least this long without an update")
+	columnNames           = flag.String("columns", "", "if set, a comma-separated list of the columns to export, in order")
+	commentWindow         = flag.Duration("comment-window", 30*24*time.Hour, "how far back to count comments for the comments_last_30d column")
+	cycle                 = flag.String("cycle", "", "title of the milestone for the release in development, for the blocks_release column (default: the earliest open Go1.N milestone)")
+	dedupe                = flag.Bool("dedupe", false, "with -append, skip issues whose numbers already appear in the file")
+	diffAgainst           = flag.String("diff-against", "", "if set, the path of a previous CSV export; report only the issues that differ from it")
+	diffAssignees         = flag.Bool("diff-assignees", false, "with -diff-against, report only the issues whose assignees changed, with their old and new assignees")
+	digest                = flag.Bool("digest", false, "after writing the output, print its SHA-256 digest to stderr")
+	extractRefs           = flag.Bool("extract-refs", false, "add an external_refs column listing references to issues in other repositories (owner/repo#number) in each issue's body")
+	format                = flag.String("format", "csv", "output format: csv, json (one object per line), json-envelope (a single object with metadata), atom (a feed with one entry per issue), or bigquery (streamed to -bq-table)")
+	groupBy               = flag.String("group-by", "state", "with -mode=summary, the column to count issues by, or two comma-separated columns for a cross-tabulation")
+	heatCommentWeight     = flag.Float64("heat-comment-weight", 1, "weight of each comment within -comment-window in the discussion_heat column")
+	includeFrozen         = flag.Bool("include-frozen", false, "include issues that were locked due to age, and add an is_frozen column")
+	isoWeek               = flag.Bool("iso-week", false, "add created_week and updated_week columns with ISO 8601 week numbers")
+	maxAge                = flag.Duration("max-age", 0, "if nonzero, include only issues created at most this long ago")
+	minAge                = flag.Duration("min-age", 0, "include only issues created at least this long ago")
+	mode                  = flag.String("mode", "issues", "what to report: "+strings.Join(modeNames(), ", "))
+	nowFlag               = flag.String("now", "", "if set, an RFC 3339 time (such as 2019-05-01T00:00:00Z) to use instead of the current time for all age and staleness computations")
+	nullValue             = flag.String("null-value", "", "in CSV output, the value to write for empty fields (for example, \\N for PostgreSQL COPY)")
+	numbersFile           = flag.String("numbers", "", "if set, the path of a file of newline-separated issue numbers; include only those issues")
+	outFile               = flag.String("o", "", "if set, write output to this file instead of stdout (or, with -chunk-size or -mode=tree, to files in this directory)")
+	preserveOrder         = flag.Bool("preserve-order", false, "with -numbers, write issues in the order they appear in the file")
+	preset                = flag.String("preset", "", "if set, a named set of default columns and filters: "+strings.Join(presetNames(), ", "))
+	queryFile             = flag.String("query", "", "if set, the path of a file of saved flag settings (one name=value per line); flags on the command line take precedence")
+	recent                = flag.Duration("recent", 7*24*time.Hour, "how far back to look for the labeled_recently and opened_recently columns")
+	redactLogins          = flag.Bool("redact-logins", false, "replace GitHub logins in the output with pseudonyms that are consistent within a run (or across runs with the same $GOISSUES_REDACT_KEY)")
+	teamsFile             = flag.String("teams", "", "if set, the path of a file mapping GitHub logins to team names (\"login team\" per line); adds a team column for the primary assignee")
+	triageSLA             = flag.Duration("triage-sla", 0, "if nonzero, add an sla_breach column reporting whether each open issue has remained untriaged for longer than this since it was created")
+	untriagedTitleRE      = flag.String("untriaged-title-regexp", "", "if set, add a title_needs_triage column reporting whether each issue's title matches this regular expression")
+	verbose               = flag.Bool("v", false, "print the effective flags to stderr before the output")
+	whenMilestoneMismatch = flag.Bool("when-milestone-mismatch", false, "include only issues whose labels override the \"when\" implied by their milestone, and add a milestone_when column")
+	withSnapshot          = flag.Bool("with-snapshot", false, "add a snapshot_at column with the time of the latest data in the corpus")
+	writeHeader           = flag.Bool("header", false, "in CSV output of issues, write a header row of column names before the records (the other reports always have one)")
+)
+
+func init() {
+	flag.Var(&checkIDsMode, "check-ids", "at startup, warn about label and milestone IDs that do not appear in the corpus; if \"strict\", exit instead")
+}
+
+// now is the time at which the run started, or the time set by -now.
+// All age and staleness computations are relative to it.
+var now time.Time
+
+// startTime returns the time to use as now: the time given by the value of
+// -now, in RFC 3339 format, or the current time if the value is empty.
+func startTime(nowValue string) (time.Time, error) {
+	if nowValue == "" {
+		return time.Now(), nil
+	}
+	return time.Parse(time.RFC3339, nowValue)
+}
+
 func main() {
+	flag.Parse()
+	if *queryFile != "" {
+		if err := applyQuery(*queryFile); err != nil {
+			fatalf(exitFailure, "%v", err)
+		}
+	}
+	if *preset != "" {
+		if err := applyPreset(*preset); err != nil {
+			fatalf(exitFailure, "%v", err)
+		}
+	}
+	var err error
+	if now, err = startTime(*nowFlag); err != nil {
+		fatalf(exitFailure, "-now: %v", err)
+	}
+	if *verbose {
+		fmt.Fprintln(os.Stderr, commandLine())
+	}
+	if _, ok := ageUnitDays[*ageUnits]; !ok {
+		fatalf(exitFailure, "unrecognized -age-units %q (want days, weeks, or months)", *ageUnits)
+	}
+	if _, ok := modes[*mode]; !ok {
+		fatalf(exitFailure, "unrecognized -mode %q (want one of %s)", *mode, strings.Join(modeNames(), ", "))
+	}
+	if *maxAge > 0 && *minAge > *maxAge {
+		fatalf(exitFailure, "-min-age (%v) exceeds -max-age (%v)", *minAge, *maxAge)
+	}
+	if *teamsFile != "" {
+		teams, err = loadTeams(*teamsFile)
+		if err != nil {
+			fatalf(exitFailure, "%v", err)
+		}
+	}
+	if *chunkSize > 0 {
+		if *outFile == "" {
+			fatalf(exitFailure, "-chunk-size requires -o")
+		}
+		if *diffAgainst != "" {
+			fatalf(exitFailure, "-chunk-size cannot be combined with -diff-against")
+		}
+	}
+	if *appendOutput {
+		switch {
+		case *outFile == "":
+			fatalf(exitFailure, "-append requires -o")
+		case *format != "csv":
+			fatalf(exitFailure, "-append requires -format=csv")
+		case *chunkSize > 0 || *diffAgainst != "":
+			fatalf(exitFailure, "-append cannot be combined with -chunk-size or -diff-against")
+		}
+	}
+	if *format == "bigquery" {
+		switch {
+		case *bqTable == "":
+			fatalf(exitFailure, "-format=bigquery requires -bq-table")
+		case *outFile != "" || *chunkSize > 0 || *diffAgainst != "":
+			fatalf(exitFailure, "-format=bigquery cannot be combined with -o, -chunk-size, or -diff-against")
+		}
+	}
+	if *redactLogins && (*diffAgainst != "" || *appendOutput) && os.Getenv(redactKeyEnv) == "" {
+		// Without a fixed key, the pseudonyms in the previous export won't
+		// match those of this run.
+		fatalf(exitFailure, "-redact-logins with -diff-against or -append requires $%s", redactKeyEnv)
+	}
+	if *diffAssignees && *diffAgainst == "" {
+		fatalf(exitFailure, "-diff-assignees requires -diff-against")
+	}
+	if staleCL.set && *clStale == 0 {
+		fatalf(exitFailure, "-stale-cl requires -cl-stale")
+	}
+	if *digest && (*appendOutput || *chunkSize > 0 || *format == "bigquery" || *mode == "tree") {
+		fatalf(exitFailure, "-digest cannot be combined with -append, -chunk-size, -format=bigquery, or -mode=tree")
+	}
+	if (*format == "json-envelope" || *format == "atom") && (*chunkSize > 0 || *diffAgainst != "") {
+		fatalf(exitFailure, "-format=%s cannot be combined with -chunk-size or -diff-against", *format)
+	}
+
 	corpus, err := godata.Get(context.Background())
 	if err != nil {
-		log.Fatal(err)
+		fatalf(exitFailure, "%v", err)
 	}
 
 	project := corpus.Gerrit().Project("go.googlesource.com", "go")
 	if project == nil {
-		log.Fatal("go.googlesource.com/go not found")
+		fatalf(exitNotFound, "go.googlesource.com/go not found")
 	}
 
 	repo := corpus.GitHub().Repo("golang", "go")
 	if repo == nil {
-		log.Fatal("github.com/golang/go not found")
+		fatalf(exitNotFound, "github.com/golang/go not found")
 	}
+	runCheckIDs(repo, checkIDsMode)
 
-	issueHasCL := map[int32]bool{}
-	err = project.ForeachOpenCL(func(cl *maintner.GerritCL) error {
-		switch cl.Status {
-		case "merged", "abandoned":
-			return nil
-		}
-		hasRef := false
-		for _, ref := range cl.GitHubIssueRefs {
-			if ref.Repo == repo {
-				hasRef = true
-				break
-			}
-		}
-		if !hasRef {
-			return nil
-		}
-		if len(cl.Metas) >= 1 {
-			meta := cl.Metas[len(cl.Metas)-1]
-			for _, vote := range meta.LabelVotes()["Code-Review"] {
-				if vote == -2 {
-					return nil
-				}
-			}
-		}
-		for _, ref := range cl.GitHubIssueRefs {
-			if ref.Repo == repo {
-				issueHasCL[ref.Number] = true
-			}
-		}
-		return nil
-	})
+	cls, err := scanCLs(project, repo)
 	if err != nil {
-		log.Fatal(err)
+		fatalf(exitFailure, "%v", err)
 	}
 
-	w := csv.NewWriter(os.Stdout)
-
-	err = repo.ForeachIssue(func(i *maintner.GitHubIssue) error {
-		if i.NotExist || i.PullRequest || (i.Locked && i.HasLabelID(frozenDueToAgeID)) {
-			return nil
+	var numbers []int32
+	if *numbersFile != "" {
+		numbers, err = loadNumbers(*numbersFile)
+		if err != nil {
+			fatalf(exitFailure, "%v", err)
 		}
+		warnMissing(repo, numbers)
+	}
 
-		number := strconv.FormatInt(int64(i.Number), 10)
-		updated := i.Updated.Format("2006-01-02")
-
-		state := ""
-		switch {
-		case i.Closed:
-			state = "closed"
-		case i.Locked:
-			state = "locked"
-		}
+	if err := modes[*mode](repo, selectIssues(repo, cls, numbers)); err != nil {
+		fatalf(exitFailure, "%v", err)
+	}
+}
 
-		when := ""
-		if i.Milestone != nil {
-			switch i.Milestone.Number {
-			case unplannedMilestone:
-				if i.HasLabelID(helpWantedID) {
-					when = "help"
-				} else {
-					when = "unplanned"
-				}
-			case unreleasedMilestone:
-				when = "unreleased"
-			case proposalMilestone:
-				when = "proposal"
-			case go2Milestone:
-				when = "go2"
-			case gccgoMilestone:
-				when = "gccgo"
-			case gollvmMilestone:
-				when = "gollvm"
+// selectIssues returns a foreachIssue that visits the issues in repo that
+// pass the filters, given the CLs that refer to each. If numbers is non-nil,
+// only the issues it lists are visited: in the listed order if
+// -preserve-order is set, and otherwise in the repo's order.
+func selectIssues(repo *maintner.GitHubRepo, cls map[int32]*issueCLs, numbers []int32) foreachIssue {
+	return func(fn func(*issue) error) error {
+		visit := func(gi *maintner.GitHubIssue) error {
+			if gi.NotExist || gi.PullRequest || (frozen(gi) && !*includeFrozen) {
+				return nil
+			}
+			i := newIssue(gi, cls[gi.Number])
+			if !include(i) {
+				return nil
 			}
+			return fn(i)
 		}
 
-		for _, l := range i.Labels {
-			switch l.ID {
-			case waitingForInfoID, proposalHoldID:
-				switch state {
-				case "", "deciding":
-					state = "waiting"
-				}
-			case needsDecisionID:
-				switch state {
-				case "":
-					state = "deciding"
-				}
-
-			case soonID:
-				when = "soon"
-			case releaseBlockerID:
-				switch when {
-				case "", "early", "feature", "performance", "test", "doc":
-					if i.Milestone != nil {
-						when = i.Milestone.Title
-					} else {
-						when = "release"
+		switch {
+		case numbers == nil:
+			return repo.ForeachIssue(visit)
+		case *preserveOrder:
+			for _, n := range numbers {
+				if gi := repo.Issue(n); gi != nil {
+					if err := visit(gi); err != nil {
+						return err
 					}
 				}
-			case earlyInCycleID:
-				switch when {
-				case "", "feature", "performance", "test", "doc":
-					when = "early"
-				}
-			case featureRequestID:
-				switch when {
-				case "", "performance", "test", "doc":
-					when = "feature"
-				}
-			case performanceID, toolSpeedID:
-				switch when {
-				case "", "test", "doc":
-					when = "performance"
-				}
-			case testingID:
-				switch when {
-				case "", "doc":
-					when = "test"
-				}
-			case documentationID:
-				switch when {
-				case "":
-					when = "doc"
-				}
 			}
-		}
-
-		if state == "" {
-			if issueHasCL[i.Number] {
-				state = "pending"
-			} else {
-				state = "open"
-			}
-		}
-
-		var who strings.Builder
-		for _, a := range i.Assignees {
-			if a.Login == "" {
-				continue
-			}
-			if who.Len() > 0 {
-				who.WriteString(",")
+			return nil
+		default:
+			wanted := map[int32]bool{}
+			for _, n := range numbers {
+				wanted[n] = true
 			}
-			who.WriteString(a.Login)
+			return repo.ForeachIssue(func(gi *maintner.GitHubIssue) error {
+				if !wanted[gi.Number] {
+					return nil
+				}
+				return visit(gi)
+			})
 		}
-
-		return w.Write([]string{number, updated, state, when, who.String(), i.Title})
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	w.Flush()
-	if err := w.Error(); err != nil {
-		log.Fatal(err)
 	}
 }
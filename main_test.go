@@ -0,0 +1,206 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"golang.org/x/build/maintner"
+	"golang.org/x/build/maintner/maintpb"
+)
+
+// testNow is the current time as far as the tests are concerned.
+var testNow = time.Date(2019, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+// withFlags resets the command-line flags to their defaults, sets the flags
+// given as name=value pairs (or just name, for boolean flags), and sets now to
+// testNow. It returns a function that restores the defaults.
+//
+// The flags are registered anew in a fresh flag.CommandLine, so that
+// flag.Visit reports only the flags set by the test.
+func withFlags(t *testing.T, settings ...string) (restore func()) {
+	t.Helper()
+
+	saved := flag.CommandLine
+	saved.VisitAll(resetFlag)
+	fs := flag.NewFlagSet(saved.Name(), flag.ContinueOnError)
+	saved.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+	flag.CommandLine = fs
+	now = testNow
+
+	restore = func() {
+		saved.VisitAll(resetFlag)
+		flag.CommandLine = saved
+		now = time.Time{}
+	}
+	for _, s := range settings {
+		name, value := s, "true"
+		if i := strings.Index(s, "="); i >= 0 {
+			name, value = s[:i], s[i+1:]
+		}
+		if err := flag.Set(name, value); err != nil {
+			restore()
+			t.Fatalf("-%s: %v", name, err)
+		}
+	}
+	return restore
+}
+
+// resetFlag sets f to its default value, unless it belongs to the testing
+// package.
+func resetFlag(f *flag.Flag) {
+	if strings.HasPrefix(f.Name, "test.") {
+		return
+	}
+	// The list-valued flags accumulate values rather than replacing them,
+	// so they are cleared directly.
+	switch v := f.Value.(type) {
+	case *listFlag:
+		*v = nil
+	case *boolFilter:
+		*v = boolFilter{}
+	case *checkMode:
+		*v = checkOff
+	case labelValues:
+		for name := range v {
+			delete(v, name)
+		}
+	default:
+		f.Value.Set(f.DefValue)
+	}
+}
+
+// A mutationSource is a maintner.MutationSource that replays a fixed list of
+// mutations.
+type mutationSource []*maintpb.Mutation
+
+func (s mutationSource) GetMutations(ctx context.Context) <-chan maintner.MutationStreamEvent {
+	ch := make(chan maintner.MutationStreamEvent, len(s)+1)
+	for _, m := range s {
+		ch <- maintner.MutationStreamEvent{Mutation: m}
+	}
+	ch <- maintner.MutationStreamEvent{End: true}
+	return ch
+}
+
+// newTestCorpus returns a corpus holding the data in the given mutations.
+func newTestCorpus(t *testing.T, muts ...*maintpb.Mutation) *maintner.Corpus {
+	t.Helper()
+
+	// Maintner logs its progress loading the mutations, which is noise here.
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(ioutil.Discard)
+
+	corpus := new(maintner.Corpus)
+	if err := corpus.Initialize(context.Background(), mutationSource(muts)); err != nil {
+		t.Fatal(err)
+	}
+	return corpus
+}
+
+// newTestRepo returns the golang/go repo of a corpus holding the given
+// issues, which need not set Owner or Repo.
+func newTestRepo(t *testing.T, issues ...*maintpb.GithubIssueMutation) *maintner.GitHubRepo {
+	t.Helper()
+	muts := []*maintpb.Mutation{{Github: &maintpb.GithubMutation{Owner: "golang", Repo: "go"}}}
+	for _, m := range issues {
+		m.Owner, m.Repo = "golang", "go"
+		muts = append(muts, &maintpb.Mutation{GithubIssue: m})
+	}
+	return newTestCorpus(t, muts...).GitHub().Repo("golang", "go")
+}
+
+// ts returns t as a protocol buffer timestamp.
+func ts(t time.Time) *timestamp.Timestamp {
+	p, err := ptypes.TimestampProto(t)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// visited returns the numbers of the issues that foreach visits.
+func visited(t *testing.T, foreach foreachIssue) []int32 {
+	t.Helper()
+	var numbers []int32
+	err := foreach(func(i *issue) error {
+		numbers = append(numbers, i.Number)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return numbers
+}
+
+func TestSelectFrozen(t *testing.T) {
+	frozenLabel := []*maintpb.GithubLabel{{Id: frozenDueToAgeID, Name: "FrozenDueToAge"}}
+	issues := testIssues(1, 2, 3)
+	issues[0].Locked = &maintpb.BoolChange{Val: true}
+	issues[0].AddLabel = frozenLabel
+	issues[1].Locked = &maintpb.BoolChange{Val: true}
+	repo := newTestRepo(t, issues...)
+
+	restore := withFlags(t)
+	if got, want := visited(t, selectIssues(repo, nil, nil)), []int32{2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("visited %v; want %v (skipping the frozen issue)", got, want)
+	}
+	restore()
+
+	defer withFlags(t, "include-frozen")()
+	if got, want := visited(t, selectIssues(repo, nil, nil)), []int32{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with -include-frozen: visited %v; want %v", got, want)
+	}
+	for n, want := range map[int32]string{1: "true", 2: "false", 3: "false"} {
+		i := newIssue(repo.Issue(n), nil)
+		if got := columnValue(t, repo, nil, "is_frozen", i); got != want {
+			t.Errorf("#%d (%s): is_frozen = %q; want %q", n, i.state, got, want)
+		}
+	}
+}
+
+func TestStartTime(t *testing.T) {
+	defer withFlags(t, "now=2020-03-01T00:00:00Z", "columns=number,age_days,opened_recently,assignee_stale", "assignee-stale=720h")()
+
+	var err error
+	if now, err = startTime(*nowFlag); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC); !now.Equal(want) {
+		t.Fatalf("startTime(%q) = %v; want %v", *nowFlag, now, want)
+	}
+
+	// Relative to -now, the ages are the same however late the test runs.
+	gi := &maintner.GitHubIssue{
+		Number:    1,
+		Created:   time.Date(2019, time.December, 2, 0, 0, 0, 0, time.UTC),
+		Updated:   time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Assignees: []*maintner.GitHubUser{{ID: 1, Login: "gopher"}},
+	}
+	cols, err := columns(nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := newIssue(gi, nil).row(cols), []string{"1", "90", "false", "true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("row = %q; want %q", got, want)
+	}
+
+	if _, err := startTime("2020-03-01"); err == nil {
+		t.Errorf("startTime(%q): got nil error; want an RFC 3339 parse error", "2020-03-01")
+	}
+	before := time.Now()
+	if got, err := startTime(""); err != nil || got.Before(before) || got.After(time.Now()) {
+		t.Errorf("startTime(\"\") = %v, %v; want the current time", got, err)
+	}
+}
@@ -0,0 +1,474 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/build/maintner"
+)
+
+// A foreachIssue function calls fn for each issue selected by the filters,
+// stopping at the first error.
+type foreachIssue func(fn func(*issue) error) error
+
+// modes maps each -mode to the function that writes its report.
+var modes = map[string]func(*maintner.GitHubRepo, foreachIssue) error{
+	"issues":             exportIssues,
+	"busfactor":          busFactor,
+	"labels":             labelCounts,
+	"tree":               writeTree,
+	"label-cooccurrence": labelCooccurrence,
+	"burndown":           burnDown,
+	"summary":            summarize,
+	"unclassified":       exportUnclassified,
+}
+
+// modeNames returns the names of the available modes, sorted.
+func modeNames() []string {
+	var names []string
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// exportIssues writes one record for each issue.
+func exportIssues(repo *maintner.GitHubRepo, foreach foreachIssue) error {
+	c := takeCensus(repo)
+	cols, err := columns(repo, c, defaultColumns)
+	if err != nil {
+		return err
+	}
+
+	var meta object
+	switch *format {
+	case "json-envelope":
+		// The envelope reports the number of issues before the issues
+		// themselves, so count them in a separate pass rather than holding
+		// them all in memory.
+		count := 0
+		err := foreach(func(*issue) error {
+			count++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		meta = object{
+			{"repo", repo.ID().String()},
+			{"snapshot", c.snapshot.UTC().Format(time.RFC3339)},
+			{"count", count},
+			{"filters", flagSettings()},
+		}
+	case "atom":
+		meta = object{
+			{"repo", repo.ID().String()},
+			{"snapshot", c.snapshot.UTC().Format(time.RFC3339)},
+		}
+	}
+
+	w, err := newOutput(header(cols), *writeHeader, meta)
+	if err != nil {
+		return err
+	}
+	err = foreach(func(i *issue) error {
+		return w.Write(i.row(cols))
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// exportUnclassified is like exportIssues, but writes only the open and
+// pending issues that no classification rule applies to: those with no
+// "when". These point to gaps in the labels and milestones that newIssue
+// recognizes.
+func exportUnclassified(repo *maintner.GitHubRepo, foreach foreachIssue) error {
+	return exportIssues(repo, func(fn func(*issue) error) error {
+		return foreach(func(i *issue) error {
+			if i.when != "" || (i.state != "open" && i.state != "pending") {
+				return nil
+			}
+			return fn(i)
+		})
+	})
+}
+
+// busFactor writes, for each subsystem, the number of open issues and the
+// number of distinct people assigned to them. Subsystems covered by few
+// assignees are at risk if those people become unavailable.
+func busFactor(repo *maintner.GitHubRepo, foreach foreachIssue) error {
+	type subsystemCount struct {
+		name      string
+		issues    int
+		assignees map[string]bool
+	}
+	counts := map[string]*subsystemCount{}
+	err := foreach(func(i *issue) error {
+		if i.Closed {
+			return nil
+		}
+		name := subsystem(i.Title)
+		sc := counts[name]
+		if sc == nil {
+			sc = &subsystemCount{name: name, assignees: map[string]bool{}}
+			counts[name] = sc
+		}
+		sc.issues++
+		for _, login := range i.who {
+			sc.assignees[login] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var sorted []*subsystemCount
+	for _, sc := range counts {
+		sorted = append(sorted, sc)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].assignees) != len(sorted[j].assignees) {
+			return len(sorted[i].assignees) < len(sorted[j].assignees)
+		}
+		return sorted[i].name < sorted[j].name
+	})
+
+	w, err := newOutput([]string{"subsystem", "open_issues", "assignees"}, true, nil)
+	if err != nil {
+		return err
+	}
+	for _, sc := range sorted {
+		err := w.Write([]string{sc.name, strconv.Itoa(sc.issues), strconv.Itoa(len(sc.assignees))})
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// subsystem returns the subsystem prefix of an issue title
+// (such as "cmd/go" for "cmd/go: build fails"), or "" if it has none.
+func subsystem(title string) string {
+	i := strings.Index(title, ": ")
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(title[:i])
+}
+
+// labelCounts writes the name of each label applied to any issue, along with
+// the number of issues that carry it, from most to least used.
+//
+// Maintner does not record label colors, so they are not reported.
+func labelCounts(repo *maintner.GitHubRepo, foreach foreachIssue) error {
+	counts := map[string]int{}
+	err := foreach(func(i *issue) error {
+		for _, l := range i.Labels {
+			counts[l.Name]++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	w, err := newOutput([]string{"name", "count"}, true, nil)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := w.Write([]string{name, strconv.Itoa(counts[name])}); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// labelCooccurrence writes each pair of labels that appear together on at
+// least one issue, along with the number of issues that carry both, from most
+// to least frequent. Each unordered pair is written once, with the names in
+// sorted order.
+func labelCooccurrence(repo *maintner.GitHubRepo, foreach foreachIssue) error {
+	type pair struct{ a, b string }
+	counts := map[pair]int{}
+	err := foreach(func(i *issue) error {
+		var names []string
+		for _, l := range i.Labels {
+			names = append(names, l.Name)
+		}
+		sort.Strings(names)
+		for j, a := range names {
+			for _, b := range names[j+1:] {
+				counts[pair{a, b}]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var pairs []pair
+	for p := range counts {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if counts[pairs[i]] != counts[pairs[j]] {
+			return counts[pairs[i]] > counts[pairs[j]]
+		}
+		if pairs[i].a != pairs[j].a {
+			return pairs[i].a < pairs[j].a
+		}
+		return pairs[i].b < pairs[j].b
+	})
+
+	w, err := newOutput([]string{"label_a", "label_b", "count"}, true, nil)
+	if err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		if err := w.Write([]string{p.a, p.b, strconv.Itoa(counts[p])}); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// treeColumns are the columns written by -mode=tree if -columns is not set.
+// They omit the columns computed relative to the current time, so that an
+// issue's file changes only when the issue itself does.
+const treeColumns = "key,updated,state,when,who,cls,open_cls,merged_cls,abandoned_cls,first_assigned_at,title"
+
+// writeTree writes one Markdown file per issue, named for its number,
+// to the -o directory. Each file lists the issue's fields, so that checking the
+// directory into git makes changes between exports reviewable.
+//
+// Existing files for the selected issues are overwritten. Files for other
+// issues are left alone, so that a filtered export can update part of a tree.
+func writeTree(repo *maintner.GitHubRepo, foreach foreachIssue) error {
+	if *outFile == "" {
+		return fmt.Errorf("-mode=tree requires -o")
+	}
+	cols, err := columns(repo, takeCensus(repo), treeColumns)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*outFile, 0777); err != nil {
+		return err
+	}
+
+	return foreach(func(i *issue) error {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "# %s\n\n", i.Title)
+		for j, v := range i.row(cols) {
+			if cols[j].name == "title" {
+				continue
+			}
+			if v == "" {
+				v = *nullValue
+			}
+			fmt.Fprintf(&b, "- %s: %s\n", cols[j].name, v)
+		}
+		name := fmt.Sprintf("%d.md", i.Number)
+		return ioutil.WriteFile(filepath.Join(*outFile, name), b.Bytes(), 0666)
+	})
+}
+
+// burnDown writes, for each day from the creation of the first issue in the
+// -milestone milestones through the snapshot, the number of those issues that
+// were open at the end of the day.
+//
+// The series is reconstructed by replaying the "closed" and "reopened" events
+// of the issues currently in the milestones, falling back to ClosedAt for
+// closed issues whose events do not end in a "closed" event (such as those
+// with only label events synced). Issues that were moved out of the
+// milestones are not counted, and issues moved in are counted from their
+// creation. If some such closed issue has no close time either, the history
+// cannot be replayed and burnDown writes a single row for the snapshot
+// instead.
+func burnDown(repo *maintner.GitHubRepo, foreach foreachIssue) error {
+	if len(milestones) == 0 {
+		return fmt.Errorf("-mode=burndown requires -milestone")
+	}
+
+	type change struct {
+		t     time.Time
+		delta int
+	}
+	var (
+		changes    []change
+		open       int
+		replayable = true
+	)
+	err := foreach(func(i *issue) error {
+		if !i.Closed {
+			open++
+		}
+		changes = append(changes, change{i.Created, +1})
+		closed := false
+		i.ForeachEvent(func(e *maintner.GitHubIssueEvent) error {
+			switch e.Type {
+			case "closed":
+				changes = append(changes, change{e.Created, -1})
+				closed = true
+			case "reopened":
+				changes = append(changes, change{e.Created, +1})
+				closed = false
+			}
+			return nil
+		})
+		if i.Closed && !closed {
+			if i.ClosedAt.IsZero() {
+				replayable = false
+			} else {
+				changes = append(changes, change{i.ClosedAt, -1})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w, err := newOutput([]string{"date", "open_count"}, true, nil)
+	if err != nil {
+		return err
+	}
+	end := takeCensus(repo).snapshot.UTC()
+	if !replayable || len(changes) == 0 {
+		if err := w.Write([]string{end.Format(dateFormat), strconv.Itoa(open)}); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].t.Before(changes[j].t) })
+	first := changes[0].t.UTC()
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	count := 0
+	for !day.After(end) {
+		next := day.AddDate(0, 0, 1)
+		for len(changes) > 0 && changes[0].t.Before(next) {
+			count += changes[0].delta
+			changes = changes[1:]
+		}
+		if err := w.Write([]string{day.Format(dateFormat), strconv.Itoa(count)}); err != nil {
+			return err
+		}
+		day = next
+	}
+	return w.Flush()
+}
+
+// summarize writes the number of issues for each value of the -group-by
+// column. If -group-by names two columns, it writes a cross-tabulation
+// instead: one row for each value of the first column and one count column
+// for each value of the second. Issues with no value for a column are counted
+// under "(none)".
+func summarize(repo *maintner.GitHubRepo, foreach foreachIssue) error {
+	keys := strings.Split(*groupBy, ",")
+	for i, key := range keys {
+		keys[i] = strings.TrimSpace(key)
+	}
+	if len(keys) > 2 {
+		return fmt.Errorf("-group-by accepts at most two columns, not %d", len(keys))
+	}
+	// Any available column may be a key, whether or not -columns selects it.
+	cols, _, err := availableColumns(repo, takeCensus(repo))
+	if err != nil {
+		return err
+	}
+	var group []column
+	for _, key := range keys {
+		col, ok := findColumn(cols, key)
+		if !ok {
+			return fmt.Errorf("unknown -group-by column %q", key)
+		}
+		group = append(group, col)
+	}
+
+	type cell struct{ row, col string }
+	counts := map[cell]int{}
+	rows, colValues := map[string]bool{}, map[string]bool{}
+	err = foreach(func(i *issue) error {
+		k := cell{row: group[0].value(i)}
+		if len(group) > 1 {
+			k.col = group[1].value(i)
+		}
+		rows[k.row] = true
+		colValues[k.col] = true
+		counts[k]++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sorted := func(set map[string]bool) []string {
+		var l []string
+		for s := range set {
+			l = append(l, s)
+		}
+		sort.Strings(l)
+		return l
+	}
+	// display renders a key value the same way on either axis.
+	display := func(v string) string {
+		if v == "" {
+			return "(none)"
+		}
+		return v
+	}
+
+	names := []string{keys[0], "count"}
+	var colNames []string
+	if len(group) > 1 {
+		colNames = sorted(colValues)
+		names = []string{keys[0]}
+		for _, col := range colNames {
+			names = append(names, display(col))
+		}
+	} else {
+		colNames = []string{""}
+	}
+	w, err := newOutput(names, true, nil)
+	if err != nil {
+		return err
+	}
+	for _, row := range sorted(rows) {
+		rec := []string{display(row)}
+		for _, col := range colNames {
+			rec = append(rec, strconv.Itoa(counts[cell{row, col}]))
+		}
+		if err := w.Write(rec); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
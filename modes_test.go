@@ -0,0 +1,368 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/build/maintner"
+	"golang.org/x/build/maintner/maintpb"
+)
+
+// foreachOf returns a foreachIssue that visits the given issues in order.
+func foreachOf(issues ...*issue) foreachIssue {
+	return func(fn func(*issue) error) error {
+		for _, i := range issues {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// runMode runs the report function for a mode with the current flags,
+// writing to a temporary -o file, and returns the output.
+func runMode(t *testing.T, report func(*maintner.GitHubRepo, foreachIssue) error, repo *maintner.GitHubRepo, foreach foreachIssue) string {
+	t.Helper()
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	*outFile = filepath.Join(dir, "out")
+	if err := report(repo, foreach); err != nil {
+		t.Fatal(err)
+	}
+	return readFile(t, *outFile)
+}
+
+// assigned returns an open issue with the given title and assignees.
+func assigned(number int32, title string, logins ...string) *issue {
+	gi := &maintner.GitHubIssue{Number: number, Title: title}
+	for i, login := range logins {
+		gi.Assignees = append(gi.Assignees, &maintner.GitHubUser{ID: int64(i + 1), Login: login})
+	}
+	return newIssue(gi, nil)
+}
+
+func TestBusFactor(t *testing.T) {
+	defer withFlags(t)()
+
+	closed := assigned(6, "cmd/go: fixed already", "dave")
+	closed.Closed = true
+	foreach := foreachOf(
+		assigned(1, "cmd/go: build fails", "alice"),
+		assigned(2, "cmd/go: test hangs", "alice", "bob"),
+		assigned(3, "cmd/go: vet crashes", "bob"),
+		assigned(4, "net/http: timeout", "carol"),
+		assigned(5, "net/http: leak"),
+		closed,
+		assigned(7, "no subsystem here"),
+	)
+
+	got := runMode(t, busFactor, nil, foreach)
+	want := `subsystem,open_issues,assignees
+,1,0
+net/http,2,1
+cmd/go,3,2
+`
+	if got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// labeled returns an open issue with the given labels.
+func labeled(number int32, labels ...*maintner.GitHubLabel) *issue {
+	gi := &maintner.GitHubIssue{Number: number, Labels: map[int64]*maintner.GitHubLabel{}}
+	for _, l := range labels {
+		gi.Labels[l.ID] = l
+	}
+	return newIssue(gi, nil)
+}
+
+var (
+	needsFixLabel   = &maintner.GitHubLabel{ID: needsFixID, Name: "NeedsFix"}
+	documentLabel   = &maintner.GitHubLabel{ID: documentationID, Name: "Documentation"}
+	helpWantedLabel = &maintner.GitHubLabel{ID: helpWantedID, Name: "help wanted"}
+)
+
+func TestLabelCounts(t *testing.T) {
+	defer withFlags(t)()
+
+	// The Soon label exists in the repo but is used by no included issue.
+	repo := newTestCorpus(t, &maintpb.Mutation{Github: &maintpb.GithubMutation{
+		Owner:  "golang",
+		Repo:   "go",
+		Labels: []*maintpb.GithubLabel{{Id: soonID, Name: "Soon"}, {Id: needsFixID, Name: "NeedsFix"}},
+	}}).GitHub().Repo("golang", "go")
+	foreach := foreachOf(
+		labeled(1, needsFixLabel, documentLabel),
+		labeled(2, needsFixLabel),
+		labeled(3, needsFixLabel, helpWantedLabel),
+		labeled(4, documentLabel),
+		labeled(5),
+	)
+
+	got := runMode(t, labelCounts, repo, foreach)
+	want := `name,count
+NeedsFix,3
+Documentation,2
+help wanted,1
+`
+	if got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLabelCooccurrence(t *testing.T) {
+	defer withFlags(t)()
+
+	needsInvestigation := &maintner.GitHubLabel{ID: needsInvestigationID, Name: "NeedsInvestigation"}
+	foreach := foreachOf(
+		labeled(1, needsFixLabel, documentLabel, helpWantedLabel),
+		labeled(2, needsFixLabel, documentLabel),
+		labeled(3, helpWantedLabel, needsFixLabel),
+		labeled(4, documentLabel, needsFixLabel),
+		labeled(5, needsInvestigation),
+		labeled(6),
+	)
+
+	got := runMode(t, labelCooccurrence, nil, foreach)
+	want := `label_a,label_b,count
+Documentation,NeedsFix,3
+NeedsFix,help wanted,2
+Documentation,help wanted,1
+`
+	if got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	tree := filepath.Join(dir, "tree")
+	defer withFlags(t, "o="+tree)()
+
+	repo := newTestRepo(t, testIssues(1, 2)...)
+	foreach := foreachOf(newIssue(repo.Issue(1), nil), newIssue(repo.Issue(2), nil))
+
+	// A file for an issue that is not selected.
+	if err := os.MkdirAll(tree, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tree, "99.md"), []byte("# old\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() map[string]string {
+		infos, err := ioutil.ReadDir(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{}
+		for _, fi := range infos {
+			files[fi.Name()] = readFile(t, filepath.Join(tree, fi.Name()))
+		}
+		return files
+	}
+
+	if err := writeTree(repo, foreach); err != nil {
+		t.Fatal(err)
+	}
+	first := read()
+	want := "# issue 1\n" +
+		"\n" +
+		"- key: golang/go#1\n" +
+		"- updated: 2019-04-30\n" +
+		"- state: open\n" +
+		"- when: \n" +
+		"- who: \n" +
+		"- cls: 0\n" +
+		"- open_cls: \n" +
+		"- merged_cls: \n" +
+		"- abandoned_cls: \n" +
+		"- first_assigned_at: \n"
+	if got := first["1.md"]; got != want {
+		t.Errorf("1.md:\n%s\nwant:\n%s", got, want)
+	}
+	if len(first) != 3 || first["2.md"] == "" || first["99.md"] != "# old\n" {
+		t.Errorf("files after first run: %q; want 1.md, 2.md, and an unchanged 99.md", first)
+	}
+
+	// A second run, say a day later, rewrites the same contents.
+	now = now.AddDate(0, 0, 1)
+	if err := writeTree(repo, foreach); err != nil {
+		t.Fatal(err)
+	}
+	if second := read(); !reflect.DeepEqual(second, first) {
+		t.Errorf("files after second run:\n%q\nwant:\n%q", second, first)
+	}
+}
+
+func TestBurnDown(t *testing.T) {
+	defer withFlags(t, "milestone=Go1.13")()
+
+	day := func(n int) time.Time { return testNow.AddDate(0, 0, n) }
+	issue := func(number int32, created, updated time.Time) *maintpb.GithubIssueMutation {
+		return &maintpb.GithubIssueMutation{
+			Number:         number,
+			Created:        ts(created),
+			Updated:        ts(updated),
+			MilestoneId:    500,
+			MilestoneNum:   50,
+			MilestoneTitle: "Go1.13",
+		}
+	}
+	closedAt := func(m *maintpb.GithubIssueMutation, at time.Time) *maintpb.GithubIssueMutation {
+		m.Closed = &maintpb.BoolChange{Val: true}
+		m.Event = []*maintpb.GithubIssueEvent{{Id: int64(m.Number), EventType: "closed", Created: ts(at)}}
+		return m
+	}
+	other := issue(3, day(-10), day(-1))
+	other.MilestoneId, other.MilestoneNum, other.MilestoneTitle = 501, 51, "Go1.14"
+
+	repo := newTestRepo(t,
+		closedAt(issue(1, day(-5), day(-2)), day(-2)),
+		issue(2, day(-4), day(-1)),
+		other,
+	)
+	got := runMode(t, burnDown, repo, selectIssues(repo, nil, nil))
+	want := `date,open_count
+2019-04-26,1
+2019-04-27,2
+2019-04-28,2
+2019-04-29,1
+2019-04-30,1
+`
+	if got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A closed issue whose synced events don't include the close is counted
+	// as closed from its ClosedAt.
+	labelOnly := issue(1, day(-5), day(-2))
+	labelOnly.Closed = &maintpb.BoolChange{Val: true}
+	labelOnly.ClosedAt = ts(day(-2))
+	labelOnly.Event = []*maintpb.GithubIssueEvent{{Id: 1, EventType: "labeled", Created: ts(day(-3)), Label: &maintpb.GithubLabel{Name: "NeedsFix"}}}
+	repo = newTestRepo(t, labelOnly, issue(2, day(-4), day(-1)))
+	got = runMode(t, burnDown, repo, selectIssues(repo, nil, nil))
+	if got != want {
+		t.Errorf("output with only a label event:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Without events or a close time for the closed issue, the history can't
+	// be replayed, so the series is a single snapshot.
+	unreplayable := issue(1, day(-5), day(-2))
+	unreplayable.Closed = &maintpb.BoolChange{Val: true}
+	repo = newTestRepo(t, unreplayable, issue(2, day(-4), day(-1)))
+	got = runMode(t, burnDown, repo, selectIssues(repo, nil, nil))
+	if want := "date,open_count\n2019-04-30,1\n"; got != want {
+		t.Errorf("snapshot output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSummaryCrossTab(t *testing.T) {
+	var (
+		soon          = &maintner.GitHubLabel{ID: soonID, Name: "Soon"}
+		waiting       = &maintner.GitHubLabel{ID: waitingForInfoID, Name: "WaitingForInfo"}
+		needsDecision = &maintner.GitHubLabel{ID: needsDecisionID, Name: "NeedsDecision"}
+	)
+	issues := []*issue{
+		labeled(1, soon),
+		labeled(2, soon, waiting),
+		labeled(3, documentLabel),
+		labeled(4),
+		labeled(5, needsDecision),
+		labeled(6, documentLabel, needsDecision),
+	}
+	repo := newTestRepo(t)
+
+	// selected visits the issues that pass the filters.
+	selected := func(fn func(*issue) error) error {
+		for _, i := range issues {
+			if include(i) {
+				if err := fn(i); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, tt := range []struct {
+		flags []string
+		want  string
+	}{
+		{
+			[]string{"group-by=when,state"},
+			"when,deciding,open,waiting\n" +
+				"(none),1,1,0\n" +
+				"doc,1,1,0\n" +
+				"soon,0,1,1\n",
+		},
+		{
+			// Spaces around the keys are ignored.
+			[]string{"group-by=when, state"},
+			"when,deciding,open,waiting\n" +
+				"(none),1,1,0\n" +
+				"doc,1,1,0\n" +
+				"soon,0,1,1\n",
+		},
+		{
+			[]string{"group-by=state,when"},
+			"state,(none),doc,soon\n" +
+				"deciding,1,1,0\n" +
+				"open,1,1,1\n" +
+				"waiting,0,0,1\n",
+		},
+		{
+			// The preset's -columns omits "when", which may still be a key.
+			[]string{"preset=triage", "group-by=when"},
+			"when,count\n" +
+				"(none),2\n" +
+				"doc,2\n" +
+				"soon,1\n",
+		},
+	} {
+		restore := withFlags(t, tt.flags...)
+		if *preset != "" {
+			if err := applyPreset(*preset); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := runMode(t, summarize, repo, selected); got != tt.want {
+			t.Errorf("with flags %q, output:\n%s\nwant:\n%s", tt.flags, got, tt.want)
+		}
+		restore()
+	}
+}
+
+func TestUnclassified(t *testing.T) {
+	defer withFlags(t, "header", "columns=number,state,when")()
+
+	label := func(id int64, name string) []*maintpb.GithubLabel {
+		return []*maintpb.GithubLabel{{Id: id, Name: name}}
+	}
+	issues := testIssues(1, 2, 3, 4, 5, 6, 7)
+	issues[2].AddLabel = label(soonID, "Soon")
+	issues[3].AddLabel = label(needsDecisionID, "NeedsDecision")
+	issues[4].AddLabel = label(waitingForInfoID, "WaitingForInfo")
+	issues[5].Closed = &maintpb.BoolChange{Val: true}
+	issues[6].MilestoneId, issues[6].MilestoneNum, issues[6].MilestoneTitle = 600, unplannedMilestone, "Unplanned"
+	repo, refs := newTestCLs(t, issues, testCL{number: 201, fixes: []int32{2}})
+
+	got := runMode(t, exportUnclassified, repo, selectIssues(repo, refs, nil))
+	want := `number,state,when
+1,open,
+2,pending,
+`
+	if got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,495 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A recordWriter writes exported records in some output format.
+type recordWriter interface {
+	// Write writes a single record, whose values are in the same order as
+	// the column names passed when the writer was created.
+	Write(values []string) error
+
+	// Flush writes any buffered data to the underlying io.Writer and reports
+	// any error that occurred during a previous Write or Flush.
+	Flush() error
+}
+
+// newOutput returns a recordWriter for records with the given column names,
+// writing them in the format and to the destination selected by the flags.
+//
+// If header is true, CSV output begins with a row of the column names.
+// meta describes the export as a whole, for formats that include such
+// metadata. It is nil for reports that do not support those formats.
+func newOutput(names []string, header bool, meta object) (recordWriter, error) {
+	if *appendOutput {
+		return newAppendWriter(*outFile, names, header)
+	}
+	if *format == "bigquery" {
+		ctx := context.Background()
+		ins, err := newRESTInserter(ctx, *bqTable)
+		if err != nil {
+			return nil, err
+		}
+		return newBQWriter(ctx, ins, names)
+	}
+	if *chunkSize > 0 {
+		if err := os.MkdirAll(*outFile, 0777); err != nil {
+			return nil, err
+		}
+		// Remove the parts of any previous export, which may have had more
+		// of them than this one will.
+		old, err := filepath.Glob(filepath.Join(*outFile, "part-[0-9][0-9][0-9][0-9].*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range old {
+			if err := os.Remove(file); err != nil {
+				return nil, err
+			}
+		}
+		return &chunkWriter{dir: *outFile, format: *format, names: names, size: *chunkSize}, nil
+	}
+
+	// Read the previous export before creating the output, which may replace
+	// the same file.
+	var prev *export
+	if *diffAgainst != "" {
+		var err error
+		prev, err = readExport(*diffAgainst, names)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Write -o to a temporary file in the same directory and rename it into
+	// place when flushed, so that a failed run leaves any existing file intact.
+	var (
+		out = os.Stdout
+		tmp *os.File
+	)
+	if *outFile != "" {
+		var err error
+		tmp, err = ioutil.TempFile(filepath.Dir(*outFile), "."+filepath.Base(*outFile)+".tmp")
+		if err != nil {
+			return nil, err
+		}
+		mode := os.FileMode(0644)
+		if fi, err := os.Stat(*outFile); err == nil {
+			mode = fi.Mode().Perm()
+		}
+		if err := tmp.Chmod(mode); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+		out = tmp
+	}
+
+	var (
+		w   recordWriter
+		dst io.Writer = out
+		h   hash.Hash
+		err error
+	)
+	if *digest {
+		h = sha256.New()
+		dst = io.MultiWriter(out, h)
+	}
+	switch {
+	case prev != nil && *diffAssignees:
+		w, err = newAssigneeDiffWriter(dst, *format, names, header, prev)
+	case prev != nil:
+		w, err = newDiffWriter(dst, *format, names, header, prev)
+	default:
+		w, err = newRecordWriter(dst, *format, names, header, meta)
+	}
+	if err != nil {
+		if tmp != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+		return nil, err
+	}
+	if h != nil {
+		w = digestWriter{w, h}
+	}
+	if tmp != nil {
+		w = &fileWriter{recordWriter: w, f: tmp, dest: *outFile}
+	}
+	return w, nil
+}
+
+// newRecordWriter returns a recordWriter for the named format that writes
+// records with the given column names to w. If header is true, CSV output
+// begins with a row of the column names.
+func newRecordWriter(w io.Writer, format string, names []string, header bool, meta object) (recordWriter, error) {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		if header {
+			if err := cw.Write(names); err != nil {
+				return nil, err
+			}
+		}
+		return csvWriter{cw, *nullValue}, nil
+	case "json":
+		return &jsonWriter{enc: json.NewEncoder(w), names: names}, nil
+	case "json-envelope":
+		if meta == nil {
+			return nil, fmt.Errorf("-format=%s is supported only with -mode=issues", format)
+		}
+		return newEnvelopeWriter(w, names, meta)
+	case "atom":
+		if meta == nil {
+			return nil, fmt.Errorf("-format=%s is supported only with -mode=issues", format)
+		}
+		repo, _ := meta.get("repo").(string)
+		snapshot, _ := meta.get("snapshot").(string)
+		updated, _ := time.Parse(time.RFC3339, snapshot)
+		return newAtomWriter(w, names, repo, updated)
+	default:
+		return nil, fmt.Errorf("unrecognized format %q (want csv, json, json-envelope, atom, or bigquery)", format)
+	}
+}
+
+// A fileWriter is a recordWriter that closes its file when flushed.
+type fileWriter struct {
+	recordWriter
+	f *os.File
+
+	// If dest is non-empty, f is a temporary file that is renamed to dest
+	// when flushed. On failure, including a failed Write, it is removed.
+	dest string
+	err  error // the error that caused the temporary file to be removed
+}
+
+func (w *fileWriter) Write(values []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	err := w.recordWriter.Write(values)
+	if err != nil && w.dest != "" {
+		// The caller may return without flushing, so don't leave the
+		// temporary file behind.
+		w.f.Close()
+		os.Remove(w.f.Name())
+		w.err = err
+	}
+	return err
+}
+
+func (w *fileWriter) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	err := w.recordWriter.Flush()
+	if closeErr := w.f.Close(); err == nil {
+		err = closeErr
+	}
+	if w.dest != "" {
+		if err == nil {
+			err = os.Rename(w.f.Name(), w.dest)
+		}
+		if err != nil {
+			os.Remove(w.f.Name())
+		}
+	}
+	return err
+}
+
+// A digestWriter is a recordWriter that, when flushed, prints the SHA-256
+// digest of everything written so far to stderr.
+type digestWriter struct {
+	recordWriter
+	h hash.Hash
+}
+
+func (w digestWriter) Flush() error {
+	if err := w.recordWriter.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "sha256:%x\n", w.h.Sum(nil))
+	return nil
+}
+
+// An appendWriter is a recordWriter that appends CSV records to an existing
+// export without rewriting it. It writes the header, if requested, only if the
+// file is empty.
+//
+// The records are buffered and appended to the file with a single write when
+// flushed, so that concurrent appends to the same file do not interleave.
+type appendWriter struct {
+	file string
+	buf  bytes.Buffer
+	w    csvWriter
+
+	// If dedupe is set, existing holds the issue numbers already present in
+	// the file, and records for those issues are skipped.
+	numberCol int
+	existing  map[string]bool
+}
+
+func newAppendWriter(file string, names []string, header bool) (*appendWriter, error) {
+	a := &appendWriter{file: file, numberCol: -1}
+	a.w = csvWriter{csv.NewWriter(&a.buf), *nullValue}
+	for i, name := range names {
+		if name == "number" {
+			a.numberCol = i
+		}
+	}
+	if *dedupe && a.numberCol < 0 {
+		return nil, fmt.Errorf("-dedupe requires the \"number\" column")
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err != nil || fi.Size() == 0 {
+		if !header {
+			return a, nil
+		}
+		return a, a.w.w.Write(names)
+	}
+
+	prev, err := readExport(file, names)
+	if err != nil {
+		return nil, err
+	}
+	if prev.header && strings.Join(prev.names, ",") != strings.Join(names, ",") {
+		return nil, fmt.Errorf("%s: existing columns (%s) differ from those being appended (%s)", file, strings.Join(prev.names, ","), strings.Join(names, ","))
+	}
+	if *dedupe {
+		a.existing = map[string]bool{}
+		for number := range prev.records {
+			a.existing[number] = true
+		}
+	}
+	return a, nil
+}
+
+func (a *appendWriter) Write(values []string) error {
+	if a.existing != nil && a.existing[values[a.numberCol]] {
+		return nil
+	}
+	return a.w.Write(values)
+}
+
+func (a *appendWriter) Flush() error {
+	if err := a.w.Flush(); err != nil {
+		return err
+	}
+	if a.buf.Len() == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(a.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(a.buf.Bytes())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	a.buf.Reset()
+	return err
+}
+
+// A chunkWriter is a recordWriter that splits its records across files
+// named part-0001, part-0002, and so on, each holding at most size records.
+// Each file is a complete export, including its own header even without
+// -header, so that each can be processed independently. (newOutput removes
+// the parts of any previous export from the directory first.)
+type chunkWriter struct {
+	dir, format string
+	names       []string
+	size        int
+
+	part int          // number of the current file
+	n    int          // number of records in the current file
+	w    recordWriter // writer for the current file, or nil if none is open
+}
+
+func (c *chunkWriter) Write(values []string) error {
+	if c.w == nil || c.n == c.size {
+		if err := c.Flush(); err != nil {
+			return err
+		}
+		c.part++
+		c.n = 0
+		f, err := os.Create(filepath.Join(c.dir, fmt.Sprintf("part-%04d.%s", c.part, c.format)))
+		if err != nil {
+			return err
+		}
+		w, err := newRecordWriter(f, c.format, c.names, true, nil)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		c.w = &fileWriter{recordWriter: w, f: f}
+	}
+	c.n++
+	return c.w.Write(values)
+}
+
+func (c *chunkWriter) Flush() error {
+	if c.w == nil {
+		return nil
+	}
+	err := c.w.Flush()
+	c.w = nil
+	return err
+}
+
+// A csvWriter writes records as CSV rows, replacing empty values with null
+// (the -null-value string) if it is non-empty. Any header row is written by
+// the caller.
+type csvWriter struct {
+	w    *csv.Writer
+	null string
+}
+
+func (c csvWriter) Write(values []string) error {
+	if c.null != "" {
+		row := make([]string, len(values))
+		for i, v := range values {
+			if v == "" {
+				v = c.null
+			}
+			row[i] = v
+		}
+		values = row
+	}
+	return c.w.Write(values)
+}
+
+func (c csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// A jsonWriter writes each record as a JSON object on its own line.
+type jsonWriter struct {
+	enc   *json.Encoder
+	names []string
+}
+
+func (j *jsonWriter) Write(values []string) error {
+	obj := make(object, len(values))
+	for i, v := range values {
+		obj[i] = field{j.names[i], v}
+	}
+	return j.enc.Encode(obj)
+}
+
+func (j *jsonWriter) Flush() error { return nil }
+
+// An envelopeWriter writes a single JSON object of the form
+// {"meta": {...}, "issues": [...]}, streaming the records into the array.
+type envelopeWriter struct {
+	w     *bufio.Writer
+	names []string
+	n     int // number of records written
+	err   error
+}
+
+func newEnvelopeWriter(w io.Writer, names []string, meta object) (*envelopeWriter, error) {
+	m, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	e := &envelopeWriter{w: bufio.NewWriter(w), names: names}
+	e.w.WriteString(`{"meta":`)
+	e.w.Write(m)
+	e.w.WriteString(`,"issues":[`)
+	return e, nil
+}
+
+func (e *envelopeWriter) Write(values []string) error {
+	if e.err != nil {
+		return e.err
+	}
+	obj := make(object, len(values))
+	for i, v := range values {
+		obj[i] = field{e.names[i], v}
+	}
+	b, err := json.Marshal(obj)
+	if err != nil {
+		e.err = err
+		return err
+	}
+	if e.n > 0 {
+		e.w.WriteByte(',')
+	}
+	e.w.WriteString("\n")
+	e.w.Write(b)
+	e.n++
+	return nil
+}
+
+func (e *envelopeWriter) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.w.WriteString("\n]}\n")
+	e.err = e.w.Flush()
+	return e.err
+}
+
+// An object is a JSON object whose fields are marshaled in order.
+type object []field
+
+type field struct {
+	name  string
+	value interface{}
+}
+
+// get returns the value of the named field, or nil if o has no such field.
+func (o object) get(name string) interface{} {
+	for _, f := range o {
+		if f.name == name {
+			return f.value
+		}
+	}
+	return nil
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,337 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tempDir returns a new temporary directory and a function that removes it.
+func tempDir(t *testing.T) (dir string, cleanup func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "goissues-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// writeOutput writes records through newOutput with the current flags.
+func writeOutput(t *testing.T, names []string, header bool, records [][]string) {
+	t.Helper()
+	w, err := newOutput(names, header, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFile returns the contents of file as a string.
+func readFile(t *testing.T, file string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+var (
+	testNames   = []string{"number", "state", "title"}
+	testRecords = [][]string{
+		{"1", "open", "one"},
+		{"2", "closed", "two"},
+		{"3", "open", "three"},
+		{"4", "waiting", "four"},
+		{"5", "open", "five"},
+	}
+)
+
+func TestChunkWriter(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	chunks := filepath.Join(dir, "chunks")
+	restore := withFlags(t, "chunk-size=2", "o="+chunks)
+	writeOutput(t, testNames, *writeHeader, testRecords)
+	restore()
+
+	single := filepath.Join(dir, "single.csv")
+	restore = withFlags(t, "header", "o="+single)
+	writeOutput(t, testNames, *writeHeader, testRecords)
+	restore()
+
+	infos, err := ioutil.ReadDir(chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, fi := range infos {
+		files = append(files, fi.Name())
+	}
+	if want := []string{"part-0001.csv", "part-0002.csv", "part-0003.csv"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("chunk files = %q; want %q", files, want)
+	}
+
+	// Each chunk has its own header; the concatenated records, after the
+	// first header, should match the single export.
+	var concat strings.Builder
+	for i, name := range files {
+		content := readFile(t, filepath.Join(chunks, name))
+		header, rest := content, ""
+		if j := strings.Index(content, "\n"); j >= 0 {
+			header, rest = content[:j], content[j+1:]
+		}
+		if header != "number,state,title" {
+			t.Errorf("%s: header = %q; want %q", name, header, "number,state,title")
+		}
+		if i == 0 {
+			concat.WriteString(header + "\n")
+		}
+		concat.WriteString(rest)
+	}
+	if got, want := concat.String(), readFile(t, single); got != want {
+		t.Errorf("concatenated chunks:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDiffAgainstOutputFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	// Diffing against the file being replaced must read it before
+	// overwriting it.
+	file := filepath.Join(dir, "issues.csv")
+	const prev = "number,state,title\n1,open,one\n2,open,two\n"
+	if err := ioutil.WriteFile(file, []byte(prev), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer withFlags(t, "header", "o="+file, "diff-against="+file)()
+	writeOutput(t, testNames, *writeHeader, testRecords[:2])
+
+	if got, want := readFile(t, file), "number,state,title\n2,closed,two\n"; got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestJSONEnvelope(t *testing.T) {
+	defer withFlags(t, "format=json-envelope", "columns=number,state,title", "state=open")()
+
+	repo := newTestRepo(t, testIssues(1, 2, 3)...)
+	var issues []*issue
+	for _, n := range []int32{1, 2, 3} {
+		issues = append(issues, newIssue(repo.Issue(n), nil))
+	}
+	out := runMode(t, exportIssues, repo, foreachOf(issues...))
+
+	if !strings.HasPrefix(out, `{"meta":{`) {
+		t.Errorf("output does not begin with the meta object:\n%s", out)
+	}
+	var envelope struct {
+		Meta struct {
+			Repo     string
+			Snapshot string
+			Count    int
+			Filters  map[string]string
+		}
+		Issues []map[string]string
+	}
+	if err := json.Unmarshal([]byte(out), &envelope); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	meta := envelope.Meta
+	if meta.Repo != "golang/go" || meta.Count != 3 || meta.Snapshot != "2019-04-30T12:00:00Z" {
+		t.Errorf("meta = %+v; want repo golang/go, count 3, snapshot 2019-04-30T12:00:00Z", meta)
+	}
+	if want := map[string]string{"format": "json-envelope", "columns": "number,state,title", "state": "open"}; !reflect.DeepEqual(meta.Filters, want) {
+		t.Errorf("meta.filters = %v; want %v", meta.Filters, want)
+	}
+	if len(envelope.Issues) != 3 {
+		t.Fatalf("got %d issues; want 3", len(envelope.Issues))
+	}
+	if want := map[string]string{"number": "2", "state": "open", "title": "issue 2"}; !reflect.DeepEqual(envelope.Issues[1], want) {
+		t.Errorf("issues[1] = %v; want %v", envelope.Issues[1], want)
+	}
+}
+
+func TestCSVNullValue(t *testing.T) {
+	defer withFlags(t, `null-value=\N`)()
+
+	var buf bytes.Buffer
+	w, err := newRecordWriter(&buf, "csv", []string{"number", "updated", "first_assigned_at"}, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]string{"1", "2019-04-01", ""})
+	w.Write([]string{"2", "2019-04-02", "2019-03-01"})
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `number,updated,first_assigned_at
+1,2019-04-01,\N
+2,2019-04-02,2019-03-01
+`
+	if got := buf.String(); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	for _, tt := range []struct {
+		flags []string
+		want  string
+	}{
+		{nil, "number,state,title\n1,open,one\n2,open,two\n2,closed,two\n3,open,three\n"},
+		{[]string{"dedupe"}, "number,state,title\n1,open,one\n2,open,two\n3,open,three\n"},
+	} {
+		dir, cleanup := tempDir(t)
+		file := filepath.Join(dir, "issues.csv")
+		const seed = "number,state,title\n1,open,one\n2,open,two\n"
+		if err := ioutil.WriteFile(file, []byte(seed), 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		restore := withFlags(t, append([]string{"append", "header", "o=" + file}, tt.flags...)...)
+		writeOutput(t, testNames, *writeHeader, testRecords[1:3])
+		restore()
+
+		if got := readFile(t, file); got != tt.want {
+			t.Errorf("with flags %q, output:\n%s\nwant:\n%s", tt.flags, got, tt.want)
+		}
+		cleanup()
+	}
+}
+
+func TestAppendEmpty(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	// Appending to a file that does not yet exist writes the header once.
+	file := filepath.Join(dir, "issues.csv")
+	defer withFlags(t, "append", "header", "o="+file)()
+	writeOutput(t, testNames, *writeHeader, testRecords[:1])
+	writeOutput(t, testNames, *writeHeader, testRecords[1:2])
+
+	if got, want := readFile(t, file), "number,state,title\n1,open,one\n2,closed,two\n"; got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// captureStderr returns what f writes to os.Stderr.
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	tmp, err := ioutil.TempFile("", "goissues-test-stderr-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	saved := os.Stderr
+	os.Stderr = tmp
+	defer func() { os.Stderr = saved }()
+	f()
+	return readFile(t, tmp.Name())
+}
+
+func TestDigest(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	digest := func(records [][]string) (sum, content string) {
+		file := filepath.Join(dir, "issues.csv")
+		restore := withFlags(t, "digest", "header", "o="+file)
+		defer restore()
+		sum = captureStderr(t, func() { writeOutput(t, testNames, *writeHeader, records) })
+		return sum, readFile(t, file)
+	}
+
+	first, content := digest(testRecords)
+	want := fmt.Sprintf("sha256:%x\n", sha256.Sum256([]byte(content)))
+	if first != want {
+		t.Errorf("digest = %q; want %q (the SHA-256 of the output)", first, want)
+	}
+	if second, _ := digest(testRecords); second != first {
+		t.Errorf("digest of the same records changed: %q, then %q", first, second)
+	}
+
+	changed := append([][]string(nil), testRecords...)
+	changed[1] = []string{"2", "open", "two"}
+	if third, _ := digest(changed); third == first {
+		t.Errorf("digest %q unchanged after a record changed", third)
+	}
+}
+
+// A failingWriter is a recordWriter whose writes fail.
+type failingWriter struct{}
+
+func (failingWriter) Write(values []string) error { return errors.New("write failed") }
+func (failingWriter) Flush() error                { return nil }
+
+func TestFileWriterWriteError(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	tmp, err := ioutil.TempFile(dir, ".out.tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &fileWriter{recordWriter: failingWriter{}, f: tmp, dest: filepath.Join(dir, "out")}
+	if err := w.Write([]string{"1"}); err == nil {
+		t.Fatal("Write succeeded unexpectedly")
+	}
+
+	// The caller returns the error without flushing.
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range infos {
+		t.Errorf("after failed Write, found %s in the output directory", fi.Name())
+	}
+}
+
+func TestChunkWriterRemovesOldParts(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	chunks := filepath.Join(dir, "chunks")
+	defer withFlags(t, "chunk-size=2", "o="+chunks)()
+	writeOutput(t, testNames, *writeHeader, testRecords)
+	if err := ioutil.WriteFile(filepath.Join(chunks, "README"), []byte("not a part\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	writeOutput(t, testNames, *writeHeader, testRecords[:2])
+
+	infos, err := ioutil.ReadDir(chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, fi := range infos {
+		files = append(files, fi.Name())
+	}
+	if want := []string{"README", "part-0001.csv"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("files after second run = %q; want %q", files, want)
+	}
+}
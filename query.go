@@ -0,0 +1,154 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// presets maps the name of each -preset to the flag settings it implies,
+// in the same syntax as a query file.
+var presets = map[string][]string{
+	// triage: issues that are waiting on a maintainer to classify them or
+	// to make a decision, with the signals that show how long they have been
+	// waiting.
+	"triage": {
+		"state=open,deciding",
+		"columns=key,updated,state,untriaged_days,opened_recently,labeled_recently,title",
+	},
+
+	// release: issues that should be resolved in the current or upcoming
+	// release, and the progress of their milestones.
+	"release": {
+		"when=release,soon,Go1.*",
+		"state=open,pending,blocked,waiting,deciding",
+		"columns=key,when,state,who,updated,milestone_pct,title",
+	},
+
+	// workload: open issues along with who is assigned to them and whether
+	// the assignment has gone quiet for 90 days.
+	"workload": {
+		"state=open,pending,blocked,waiting,deciding",
+		"assignee-stale=2160h",
+		"columns=key,who,state,when,updated,assignee_stale,title",
+	},
+}
+
+// presetNames returns the names of the available presets, sorted.
+func presetNames() []string {
+	var names []string
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPreset sets flags from the named preset.
+// Flags that have already been set, either on the command line or by a query
+// file, take precedence over those in the preset.
+func applyPreset(name string) error {
+	settings, ok := presets[name]
+	if !ok {
+		return fmt.Errorf("unknown preset %q (want one of %s)", name, strings.Join(presetNames(), ", "))
+	}
+	return applySettings("preset "+name, settings)
+}
+
+// applyQuery sets flags from the saved query in file.
+//
+// A query file contains one flag per line, written as name=value (or just
+// name, for boolean flags) without the leading dash. Blank lines and lines
+// beginning with '#' are ignored. Flags set explicitly on the command line
+// take precedence over those in the query.
+func applyQuery(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return applySettings(file, strings.Split(string(data), "\n"))
+}
+
+// applySettings sets each flag named in lines that has not already been set.
+// source names the origin of the lines, for error messages.
+func applySettings(source string, lines []string) error {
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for n, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value := line, "true"
+		if i := strings.Index(line, "="); i >= 0 {
+			name, value = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+		name = strings.TrimLeft(name, "-")
+
+		switch {
+		case name == "query", name == "preset":
+			return fmt.Errorf("%s:%d: -%s cannot be nested", source, n+1, name)
+		case flag.Lookup(name) == nil:
+			return fmt.Errorf("%s:%d: unknown flag %q", source, n+1, name)
+		case explicit[name]:
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: %v", source, n+1, err)
+		}
+	}
+	return nil
+}
+
+// loginFlags are the flags whose values are lists of GitHub logins, which
+// are shown as pseudonyms in flagSettings with -redact-logins.
+var loginFlags = map[string]bool{
+	"assignee":         true,
+	"exclude-assignee": true,
+}
+
+// flagSettings returns the flags that have been set, whether on the command
+// line, by a query file, or by a preset, along with their values.
+func flagSettings() object {
+	var settings object
+	flag.Visit(func(f *flag.Flag) {
+		v := f.Value.String()
+		if loginFlags[f.Name] {
+			v = displayLogins(*f.Value.(*listFlag))
+		}
+		settings = append(settings, field{f.Name, v})
+	})
+	return settings
+}
+
+// commandLine returns a canonical description of how the export was produced:
+// a goissues command line with every flag that has been set, in sorted order.
+// The ages and staleness in the export depend on the time it was run, so -now
+// is always included, even if the time came from the clock.
+func commandLine() string {
+	settings := flagSettings()
+	if flag.Lookup("now").Value.String() == "" {
+		settings = append(settings, field{"now", now.UTC().Format(time.RFC3339)})
+		sort.SliceStable(settings, func(i, j int) bool { return settings[i].name < settings[j].name })
+	}
+
+	var b strings.Builder
+	b.WriteString("goissues")
+	for _, f := range settings {
+		v := f.value.(string)
+		if v == "" || strings.ContainsAny(v, " \t\n\"'\\$*?[]{}()<>|&;#~") {
+			v = strconv.Quote(v)
+		}
+		fmt.Fprintf(&b, " -%s=%s", f.name, v)
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/build/maintner"
+)
+
+// writeQuery writes a query file with the given lines to a new temporary
+// directory, returning its path and a function that removes the directory.
+func writeQuery(t *testing.T, lines ...string) (file string, cleanup func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "goissues-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file = filepath.Join(dir, "query.txt")
+	if err := ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")), 0666); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return file, func() { os.RemoveAll(dir) }
+}
+
+func TestApplyQuery(t *testing.T) {
+	// -state is set on the command line, so the query's value is ignored.
+	defer withFlags(t, "state=closed")()
+
+	file, cleanup := writeQuery(t,
+		"# Closed Go 1.13 garbage collector issues.",
+		"",
+		"state=open,deciding",
+		"  milestone = Go1.13  ",
+		"label=GarbageCollector",
+		"-v",
+	)
+	defer cleanup()
+
+	if err := applyQuery(file); err != nil {
+		t.Fatal(err)
+	}
+	if want := (listFlag{"closed"}); !reflect.DeepEqual(states, want) {
+		t.Errorf("-state = %q; want %q", states, want)
+	}
+	if want := (listFlag{"Go1.13"}); !reflect.DeepEqual(milestones, want) {
+		t.Errorf("-milestone = %q; want %q", milestones, want)
+	}
+	if want := (listFlag{"GarbageCollector"}); !reflect.DeepEqual(labelFilter, want) {
+		t.Errorf("-label = %q; want %q", labelFilter, want)
+	}
+	if !*verbose {
+		t.Errorf("-v = false; want true")
+	}
+
+	go113 := &maintner.GitHubMilestone{ID: 500, Number: 50, Title: "Go1.13"}
+	gc := map[int64]*maintner.GitHubLabel{1: {ID: 1, Name: "GarbageCollector"}}
+	for _, tt := range []struct {
+		gi   *maintner.GitHubIssue
+		want bool
+	}{
+		{&maintner.GitHubIssue{Number: 1, Closed: true, Milestone: go113, Labels: gc}, true},
+		{&maintner.GitHubIssue{Number: 2, Milestone: go113, Labels: gc}, false},
+		{&maintner.GitHubIssue{Number: 3, Closed: true, Labels: gc}, false},
+		{&maintner.GitHubIssue{Number: 4, Closed: true, Milestone: go113}, false},
+	} {
+		if got := include(newIssue(tt.gi, nil)); got != tt.want {
+			t.Errorf("include(#%d) = %v; want %v", tt.gi.Number, got, tt.want)
+		}
+	}
+}
+
+func TestApplyQueryErrors(t *testing.T) {
+	defer withFlags(t)()
+
+	for _, tt := range []struct {
+		line, want string
+	}{
+		{"labels=GarbageCollector", `:2: unknown flag "labels"`},
+		{"preset=triage", ":2: -preset cannot be nested"},
+		{"max-age=soon", ":2: parse error"},
+	} {
+		file, cleanup := writeQuery(t, "state=open", tt.line)
+		err := applyQuery(file)
+		cleanup()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("applyQuery with %q: got error %v; want %q", tt.line, err, tt.want)
+		}
+	}
+}
+
+func TestTriagePreset(t *testing.T) {
+	defer withFlags(t)()
+
+	if err := applyPreset("triage"); err != nil {
+		t.Fatal(err)
+	}
+	if want := (listFlag{"open", "deciding"}); !reflect.DeepEqual(states, want) {
+		t.Errorf("-state = %q; want %q", states, want)
+	}
+	cols, err := columns(nil, nil, defaultColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"key", "updated", "state", "untriaged_days", "opened_recently", "labeled_recently", "title"}
+	if got := header(cols); !reflect.DeepEqual(got, want) {
+		t.Errorf("columns = %q; want %q", got, want)
+	}
+}
+
+func TestPresetOverride(t *testing.T) {
+	defer withFlags(t, "columns=number,title", "state=waiting")()
+
+	if err := applyPreset("triage"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "number,title"; *columnNames != want {
+		t.Errorf("-columns = %q; want %q", *columnNames, want)
+	}
+	if want := (listFlag{"waiting"}); !reflect.DeepEqual(states, want) {
+		t.Errorf("-state = %q; want %q", states, want)
+	}
+}
+
+func TestUnknownPreset(t *testing.T) {
+	defer withFlags(t)()
+
+	err := applyPreset("nonesuch")
+	if err == nil || !strings.Contains(err.Error(), "release, triage, workload") {
+		t.Errorf("applyPreset(\"nonesuch\") = %v; want error listing the presets", err)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	defer withFlags(t, "v", "state=open", "milestone=Go1.13", "exclude-milestone=Unplanned", "o=my issues.csv", "null-value=", "preset=triage")()
+	if err := applyPreset(*preset); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flags appear in sorted order, including those set by the preset, with
+	// values quoted where a shell would need it. -state was set explicitly,
+	// so the preset leaves it alone. -now was not set, so the time used for
+	// the export is recorded in its place.
+	want := `goissues -columns=key,updated,state,untriaged_days,opened_recently,labeled_recently,title -exclude-milestone=Unplanned -milestone=Go1.13 -now=2019-05-01T12:00:00Z -null-value="" -o="my issues.csv" -preset=triage -state=open -v=true`
+	if got := commandLine(); got != want {
+		t.Errorf("commandLine() =\n\t%s\nwant:\n\t%s", got, want)
+	}
+
+	// An explicit -now appears once, with the value given.
+	if err := flag.Set("now", "2020-03-01T00:00:00Z"); err != nil {
+		t.Fatal(err)
+	}
+	if got := commandLine(); strings.Count(got, " -now=") != 1 || !strings.Contains(got, " -now=2020-03-01T00:00:00Z ") {
+		t.Errorf("commandLine() with -now set =\n\t%s\nwant a single -now=2020-03-01T00:00:00Z", got)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+)
+
+// redactKeyEnv is the environment variable holding the key for -redact-logins
+// pseudonyms, if they should be stable across runs.
+const redactKeyEnv = "GOISSUES_REDACT_KEY"
+
+var (
+	redactOnce sync.Once
+	redactKey  []byte
+)
+
+// displayLogin returns login as it should appear in the output: either
+// unchanged, or with -redact-logins, replaced by a pseudonym.
+//
+// Pseudonyms are keyed by the value of $GOISSUES_REDACT_KEY, or if that is
+// unset, by a random value chosen once per run. So a given login always maps
+// to the same pseudonym within an export (and grouping by login still works),
+// and across exports made with the same key, but cannot be recovered by
+// hashing candidate logins without the key.
+func displayLogin(login string) string {
+	if !*redactLogins || login == "" {
+		return login
+	}
+	redactOnce.Do(func() {
+		if key := os.Getenv(redactKeyEnv); key != "" {
+			redactKey = []byte(key)
+			return
+		}
+		redactKey = make([]byte, 32)
+		if _, err := rand.Read(redactKey); err != nil {
+			fatalf(exitFailure, "%v", err)
+		}
+	})
+	mac := hmac.New(sha256.New, redactKey)
+	mac.Write([]byte(login))
+	// 64 bits is plenty to keep the logins of any one repo distinct.
+	return "user-" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// displayLogins returns the comma-separated display forms of logins.
+func displayLogins(logins []string) string {
+	display := make([]string, len(logins))
+	for i, login := range logins {
+		display[i] = displayLogin(login)
+	}
+	return strings.Join(display, ",")
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var pseudonymRE = regexp.MustCompile(`^user-[0-9a-f]{16}$`)
+
+func TestDisplayLogin(t *testing.T) {
+	defer withFlags(t, "redact-logins")()
+
+	alice, bob := displayLogin("alice"), displayLogin("bob")
+	for _, p := range []string{alice, bob} {
+		if !pseudonymRE.MatchString(p) {
+			t.Errorf("pseudonym %q does not match %v", p, pseudonymRE)
+		}
+	}
+	if again := displayLogin("alice"); again != alice {
+		t.Errorf("displayLogin(\"alice\") = %q, then %q; want consistent pseudonyms", alice, again)
+	}
+	if alice == bob {
+		t.Errorf("displayLogin(\"alice\") = displayLogin(\"bob\") = %q; want distinct pseudonyms", alice)
+	}
+	if got := displayLogin(""); got != "" {
+		t.Errorf("displayLogin(\"\") = %q; want \"\"", got)
+	}
+	if got, want := displayLogins([]string{"bob", "alice"}), bob+","+alice; got != want {
+		t.Errorf("displayLogins([bob alice]) = %q; want %q", got, want)
+	}
+}
+
+func TestDisplayLoginUnredacted(t *testing.T) {
+	defer withFlags(t)()
+
+	if got := displayLogin("alice"); got != "alice" {
+		t.Errorf("displayLogin(\"alice\") = %q; want \"alice\"", got)
+	}
+}
+
+func TestFlagSettingsRedacted(t *testing.T) {
+	defer withFlags(t, "redact-logins", "assignee=alice", "exclude-assignee=bob", "state=open")()
+
+	line := commandLine()
+	for _, login := range []string{"alice", "bob"} {
+		if strings.Contains(line, login) {
+			t.Errorf("commandLine() = %q; want %q redacted", line, login)
+		}
+	}
+	want := "-assignee=" + displayLogin("alice")
+	if !strings.Contains(line, want) {
+		t.Errorf("commandLine() = %q; want it to contain %q", line, want)
+	}
+	if !strings.Contains(line, "-state=open") {
+		t.Errorf("commandLine() = %q; want it to contain -state=open", line)
+	}
+}
+
+// pseudonymWithKey returns the pseudonym for login with $GOISSUES_REDACT_KEY
+// set to key (or unset, if key is empty), as in a fresh run.
+func pseudonymWithKey(t *testing.T, key, login string) string {
+	t.Helper()
+	saved, wasSet := os.LookupEnv(redactKeyEnv)
+	defer func() {
+		if wasSet {
+			os.Setenv(redactKeyEnv, saved)
+		} else {
+			os.Unsetenv(redactKeyEnv)
+		}
+		redactOnce, redactKey = sync.Once{}, nil
+	}()
+	if key == "" {
+		os.Unsetenv(redactKeyEnv)
+	} else {
+		os.Setenv(redactKeyEnv, key)
+	}
+	redactOnce, redactKey = sync.Once{}, nil
+	return displayLogin(login)
+}
+
+func TestRedactKey(t *testing.T) {
+	defer withFlags(t, "redact-logins")()
+
+	first := pseudonymWithKey(t, "s3cret", "alice")
+	if !pseudonymRE.MatchString(first) {
+		t.Errorf("pseudonym %q does not match %v", first, pseudonymRE)
+	}
+	if again := pseudonymWithKey(t, "s3cret", "alice"); again != first {
+		t.Errorf("with the same key, pseudonyms differ across runs: %q, then %q", first, again)
+	}
+	if other := pseudonymWithKey(t, "other", "alice"); other == first {
+		t.Errorf("with different keys, both runs give pseudonym %q", first)
+	}
+	if random := pseudonymWithKey(t, "", "alice"); random == first {
+		t.Errorf("without a key, pseudonym %q matches the keyed one", random)
+	}
+}
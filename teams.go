@@ -0,0 +1,41 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// teams maps GitHub logins to team names, as loaded from the -teams file.
+var teams map[string]string
+
+// loadTeams reads a teams file, in which each line holds a GitHub login and
+// the name of a team, separated by whitespace. If a login is listed more than
+// once, its first team is used. Blank lines and lines beginning with '#' are
+// ignored.
+func loadTeams(file string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]string{}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			return nil, fmt.Errorf("%s:%d: want login and team", file, n+1)
+		}
+		login, team := f[0], strings.Join(f[1:], " ")
+		if _, ok := m[login]; !ok {
+			m[login] = team
+		}
+	}
+	return m, nil
+}
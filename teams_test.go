@@ -0,0 +1,70 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTeams(t *testing.T) {
+	defer withFlags(t)()
+
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	file := filepath.Join(dir, "teams.txt")
+	content := `# Go team members.
+alice   compiler
+bob     tools
+alice   runtime
+
+carol   release team
+`
+	if err := ioutil.WriteFile(file, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := loadTeams(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"alice": "compiler", "bob": "tools", "carol": "release team"}; !reflect.DeepEqual(m, want) {
+		t.Errorf("loadTeams = %v; want %v", m, want)
+	}
+
+	defer func() { teams = nil }()
+	teams = m
+	for _, tt := range []struct {
+		i    *issue
+		want string
+	}{
+		{assigned(1, "x", "alice"), "compiler"},
+		{assigned(2, "x", "bob", "alice"), "tools"},
+		{assigned(3, "x", "dave", "alice"), "(none)"},
+		{assigned(4, "x", "carol"), "release team"},
+		{assigned(5, "x"), ""},
+	} {
+		if got := columnValue(t, nil, nil, "team", tt.i); got != tt.want {
+			t.Errorf("#%d (assigned to %v): team = %q; want %q", tt.i.Number, tt.i.who, got, tt.want)
+		}
+	}
+}
+
+func TestLoadTeamsError(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	file := filepath.Join(dir, "teams.txt")
+	if err := ioutil.WriteFile(file, []byte("alice compiler\nbob\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := loadTeams(file)
+	if err == nil || !strings.HasSuffix(err.Error(), ":2: want login and team") {
+		t.Errorf("loadTeams: got error %v; want %q", err, file+":2: want login and team")
+	}
+}